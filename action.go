@@ -0,0 +1,233 @@
+package admin
+
+import (
+	"fmt"
+	"launchpad.net/mgo/bson"
+	"net/http"
+	"path"
+	"reflect"
+)
+
+//ObjectAction is an action run on a single document from its detail page, like
+//resending an email or recalculating a total. Name identifies the action in its
+//url from Reverser.Action and Label is shown to the user.
+//
+//If Form is not nil, a new value of its type is loaded from the posted form and
+//passed to Run as the parameters of the action, and an empty one is rendered
+//for the user to fill in. If Allowed is not nil, the action is only shown and
+//run when it returns true for the logged in user and object. Run is passed the
+//loaded object and returns a message describing the result. A successful run is
+//audited as an ActionUpdate naming the action in a Change to the ActionField.
+type ObjectAction struct {
+	Name    string
+	Label   string
+	Form    Formable
+	Allowed func(ctx ActionContext, obj Formable) bool
+	Run     func(ctx ActionContext, obj Formable, params Formable) (string, error)
+}
+
+//checkObjectActions panics if the actions in the options have missing or
+//duplicate names, or parameter forms that can't be loaded.
+func checkObjectActions(typ reflect.Type, opt *Options) {
+	seen := map[string]bool{}
+	for _, action := range opt.Actions {
+		if action.Name == "" || action.Run == nil {
+			panic(fmt.Sprintf("Action %q on type %s needs a Name and Run", action.Label, typ))
+		}
+		if seen[action.Name] {
+			panic(fmt.Sprintf("Duplicate action %s on type %s", action.Name, typ))
+		}
+		seen[action.Name] = true
+
+		if action.Form != nil && indirectType(reflect.TypeOf(action.Form)).Kind() != reflect.Struct {
+			panic(fmt.Sprintf("Form for action %s on type %s is not a struct", action.Name, typ))
+		}
+	}
+}
+
+//objectActions returns the actions on the collection the request may run on
+//the object.
+func (a *Admin) objectActions(req *http.Request, coll string, t Formable) []ObjectAction {
+	ctx := a.actionContext(req, coll)
+
+	var actions []ObjectAction
+	for _, action := range a.types[coll].Options.Actions {
+		if action.Allowed == nil || action.Allowed(ctx, t) {
+			actions = append(actions, action)
+		}
+	}
+	return actions
+}
+
+//findAction returns the action on the collection with the name.
+func (a *Admin) findAction(coll, name string) (ObjectAction, bool) {
+	for _, action := range a.types[coll].Options.Actions {
+		if action.Name == name {
+			return action, true
+		}
+	}
+	return ObjectAction{}, false
+}
+
+//newParams returns a new value for loading the parameters of the action, or nil
+//if it has none.
+func newParams(action ObjectAction) Formable {
+	if action.Form == nil {
+		return nil
+	}
+	typ := indirectType(reflect.TypeOf(action.Form))
+	return reflect.New(typ).Interface().(Formable)
+}
+
+//loadParams loads and validates the parameters of an action from the form,
+//respecting if they are a Loader.
+func loadParams(req *http.Request, params Formable) (errors map[string]interface{}, err error) {
+	req.ParseForm()
+	if l, ok := params.(Loader); ok {
+		errors, err = l.Load(req.Form)
+	} else {
+		errors, err = Load(req.Form, params)
+	}
+
+	//do we have loading errors?
+	if (errors != nil && len(errors) > 0) || err != nil {
+		return
+	}
+
+	errors = params.Validate()
+	return
+}
+
+//parseActionRequest splits the path of an action request into the collection,
+//the id of the object and the name of the action.
+func parseActionRequest(p string) (coll, id, name string) {
+	dir, name := path.Split(path.Clean(p))
+	coll, id = parseRequest(dir)
+	if id == "" {
+		return "", "", ""
+	}
+	return
+}
+
+//Presents the action for an object in a collection, asking for any parameters
+//and confirmation, and runs it when posted.
+func (a *Admin) action(w http.ResponseWriter, req *http.Request) {
+	coll, id, name := parseActionRequest(req.URL.Path)
+
+	//ensure we have a collection, an id and an action
+	if coll == "" || id == "" || name == "" {
+		a.Renderer.NotFound(w, req)
+		return
+	}
+
+	//make sure we know about the requested collection and action
+	if !a.hasType(coll) {
+		a.Renderer.NotFound(w, req)
+		return
+	}
+	action, ok := a.findAction(coll, name)
+	if !ok {
+		a.Renderer.NotFound(w, req)
+		return
+	}
+
+	q, err := a.idQuery(coll, id)
+	if err != nil {
+		a.Renderer.NotFound(w, req)
+		return
+	}
+
+	c, t := a.collFor(coll), a.newType(coll)
+
+	//grab the data
+	if err := c.Find(a.liveQuery(coll, bson.M{"_id": q})).One(t); err != nil {
+		if err.Error() == "Document not found" {
+			a.Renderer.NotFound(w, req)
+			return
+		}
+		a.Renderer.InternalError(w, req, err)
+		return
+	}
+
+	//actions the user isn't allowed to run don't exist for them
+	ctx := a.actionContext(req, coll)
+	if action.Allowed != nil && !action.Allowed(ctx, t) {
+		a.Renderer.NotFound(w, req)
+		return
+	}
+
+	var attempted, success bool
+	var message string
+	var runErr error
+	var errors map[string]interface{}
+	params := newParams(action)
+	if req.Method == "POST" {
+		attempted = true
+
+		if params != nil {
+			errors, err = loadParams(req, params)
+			if err != nil {
+				a.Renderer.InternalError(w, req, err)
+				return
+			}
+			if errors != nil && len(errors) > 0 {
+				goto render
+			}
+		}
+
+		before, err := formValues(t)
+		if err != nil {
+			a.Renderer.InternalError(w, req, err)
+			return
+		}
+
+		message, runErr = action.Run(ctx, t, params)
+		if runErr != nil {
+			goto render
+		}
+		success = true
+
+		//the action may have changed the document, so record what it did
+		if err := c.Find(a.liveQuery(coll, bson.M{"_id": q})).One(t); err == nil {
+			after, _ := formValues(t)
+			a.audit(req, ActionUpdate, coll, id, before, after, Change{Field: ActionField, After: action.Name})
+		}
+	}
+
+render:
+	var form Form
+	if params != nil {
+		var values map[string]interface{}
+		if attempted {
+			values, err = formValues(params)
+		} else {
+			values, err = CreateEmptyValues(params)
+		}
+		if err != nil {
+			a.Renderer.InternalError(w, req, err)
+			return
+		}
+
+		form = Form{
+			object: params,
+			logger: a.logger,
+			context: TemplateContext{
+				Values: values,
+				Errors: errors,
+				Locale: a.localeFor(req),
+			},
+		}
+	}
+
+	a.renderAction(w, req, ObjectActionContext{
+		BaseContext: a.baseContext(req),
+		Collection:  coll,
+		Object:      t,
+		Action:      action,
+		Form:        form,
+		Attempted:   attempted,
+		Success:     success,
+		Message:     message,
+		Error:       runErr,
+	})
+}
@@ -0,0 +1,55 @@
+package admin
+
+import (
+	"testing"
+)
+
+func TestParseActionRequest(t *testing.T) {
+	cases := []struct {
+		path, coll, id, name string
+	}{
+		{"admin_test.T12/4f07c34779bf562daff8640c/resend", "admin_test.T12", "4f07c34779bf562daff8640c", "resend"},
+		{"admin_test.T12/4f07c34779bf562daff8640c/resend/", "admin_test.T12", "4f07c34779bf562daff8640c", "resend"},
+		{"admin_test.T12/resend", "", "", ""},
+		{"", "", "", ""},
+	}
+	for _, c := range cases {
+		coll, id, name := parseActionRequest(c.path)
+		if coll != c.coll || id != c.id || name != c.name {
+			t.Errorf("%q: Expected %q %q %q. Got %q %q %q", c.path, c.coll, c.id, c.name, coll, id, name)
+		}
+	}
+}
+
+func TestCheckObjectActions(t *testing.T) {
+	run := func(ActionContext, Formable, Formable) (string, error) { return "", nil }
+	cases := [][]ObjectAction{
+		{{Name: "", Run: run}},
+		{{Name: "resend"}},
+		{{Name: "resend", Run: run}, {Name: "resend", Run: run}},
+	}
+	for _, actions := range cases {
+		func() {
+			defer func() {
+				if err := recover(); err == nil {
+					t.Errorf("No panic with %v", actions)
+				}
+			}()
+			h := &Admin{}
+			h.Register(T12{}, "admin_test.T12", &Options{Actions: actions})
+		}()
+	}
+}
+
+func TestNewParams(t *testing.T) {
+	if p := newParams(ObjectAction{}); p != nil {
+		t.Fatalf("Expected no parameters. Got %v", p)
+	}
+
+	//a fresh value every time so requests don't share parameters
+	action := ObjectAction{Form: &T10{Level: 3}}
+	p, ok := newParams(action).(*T10)
+	if !ok || p.Level != 0 || p == action.Form {
+		t.Fatalf("Expected a new *T10. Got %#v", p)
+	}
+}
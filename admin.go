@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"github.com/zeebo/sign"
 	"io"
+	"io/fs"
 	"launchpad.net/mgo"
 	"log"
 	"net/http"
@@ -23,45 +24,86 @@ type adminHandler func(*Admin, http.ResponseWriter, *http.Request)
 
 //Admin is an http.Handler for serving up the admin pages
 type Admin struct {
-	Auth     Authorizer        //If not nil, admin is auth protected.
-	Session  *mgo.Session      //The mongo session for managing.
-	Renderer Renderer          //If nil, a default renderer is used to render the admin pages.
-	Routes   map[string]string //Routes lets you change the url paths. If nil, uses DefaultRoutes.
-	Prefix   string            //The path the admin is mounted to in the handler.
-	Key      []byte            //Key for cryptographically signing cookies. Generated if nil.
-	Logger   io.Writer         //If nil, os.Stdout is used for logging information.
+	Auth       Authorizer        //If not nil, admin is auth protected.
+	Session    *mgo.Session      //The mongo session for managing.
+	Renderer   Renderer          //If nil, a default renderer is used, serving JSON when requested.
+	Routes     map[string]string //Routes lets you change the url paths. If nil, uses DefaultRoutes.
+	Prefix     string            //The path the admin is mounted to in the handler.
+	Key        []byte            //Key for cryptographically signing cookies. Generated if nil.
+	Logger     io.Writer         //If nil, os.Stdout is used for logging information.
+	Files      BlobStore         //Where uploaded files are stored. If nil, uploads are rejected.
+	Audit      AuditLog          //If not nil, every write is recorded to it.
+	Versions   VersionStore      //If not nil, every saved version of a document is kept.
+	Webhooks   []Webhook         //Every write is posted to the hooks that select it.
+	Deliveries DeliveryStore     //Where webhook deliveries are queued. Required with Webhooks.
+	DevMode    bool              //If true, the default renderer reloads templates when they change.
+	Locales    []Locale          //Languages the admin is presented in. The first is the default.
+	Theme      Theme             //Branding of the default templates. Empty fields use the LightTheme.
+	Assets     fs.FS             //Files served on the assets route along with the built in ones.
+	Widgets    []Widget          //Panels on the dashboard of the index page.
 
 	//created on demand
-	initd       sync.Once
-	server      *http.ServeMux
-	types       map[string]collectionInfo
-	index_cache map[string][]string
-	object_id   map[reflect.Type]int
-	object_coll map[reflect.Type]string
-	auth_cache  map[*http.Request]AuthSession
-	logger      *log.Logger
+	initd        sync.Once
+	server       *http.ServeMux
+	types        map[string]collectionInfo
+	index_cache  map[string][]string
+	groups       []Group
+	object_id    map[reflect.Type]int
+	object_coll  map[reflect.Type]string
+	auth_cache   map[*http.Request]AuthSession
+	logger       *log.Logger
+	fallback     *defaultRenderer
+	assets       map[string]*asset
+	asset_urls   map[string]*asset
+	dash_mu      sync.Mutex
+	dash_cache   map[string]*cached
+	webhook_mu   sync.Mutex
+	webhook_kick chan bool
+	done         chan struct{}
+	closed       sync.Once
 }
 
 //DefaultRoutes is the mapping of actions to url paths.
 var DefaultRoutes = map[string]string{
-	"index":  "/",
-	"list":   "/list/",
-	"update": "/update/",
-	"create": "/create/",
-	"detail": "/detail/",
-	"delete": "/delete/",
-	"auth":   "/auth/",
+	"index":    "/",
+	"list":     "/list/",
+	"update":   "/update/",
+	"create":   "/create/",
+	"detail":   "/detail/",
+	"delete":   "/delete/",
+	"auth":     "/auth/",
+	"files":    "/files/",
+	"lookup":   "/lookup/",
+	"audit":    "/audit/",
+	"history":  "/history/",
+	"trash":    "/trash/",
+	"action":   "/action/",
+	"webhooks": "/webhooks/",
+	"assets":   "/assets/",
 }
 
-//routes defines the mapping of type to function for the admin
-var routes = map[string]adminHandler{
-	"index":  (*Admin).index,
-	"list":   (*Admin).list,
-	"update": (*Admin).update,
-	"create": (*Admin).create,
-	"detail": (*Admin).detail,
-	"delete": (*Admin).delete,
-	"auth":   (*Admin).auth,
+//routes defines the mapping of type to function for the admin. It is filled in
+//by init because the handlers use Reversers, which refer back to routes.
+var routes map[string]adminHandler
+
+func init() {
+	routes = map[string]adminHandler{
+		"index":    (*Admin).index,
+		"list":     (*Admin).list,
+		"update":   (*Admin).update,
+		"create":   (*Admin).create,
+		"detail":   (*Admin).detail,
+		"delete":   (*Admin).delete,
+		"auth":     (*Admin).auth,
+		"files":    (*Admin).files,
+		"lookup":   (*Admin).lookup,
+		"audit":    (*Admin).auditLog,
+		"history":  (*Admin).history,
+		"trash":    (*Admin).trash,
+		"action":   (*Admin).action,
+		"webhooks": (*Admin).webhookLog,
+		"assets":   (*Admin).asset,
+	}
 }
 
 //init sets up the admin's caches and routes.
@@ -83,9 +125,15 @@ func (a *Admin) init() {
 			a.Logger = os.Stdout
 		}
 		a.logger = log.New(a.Logger, "ADMIN", log.LstdFlags)
+		a.done = make(chan struct{})
 
+		//the default renderer also presents the pages the Renderer has no
+		//optional interface for
+		a.fallback = newDefaultRenderer(a.logger, a.DevMode, a.done)
+		a.fallback.overrides = a.templateOverrides()
+		a.fallback.asset = Reverser{a}.Asset
 		if a.Renderer == nil {
-			a.Renderer = newDefaultRenderer(a.logger)
+			a.Renderer = NegotiatingRenderer{HTML: a.fallback, JSON: JSONRenderer{}}
 		}
 
 		required := []string{"index", "list", "update", "create", "detail", "delete", "auth"}
@@ -95,10 +143,25 @@ func (a *Admin) init() {
 			}
 		}
 
+		a.checkReferences()
+		a.checkInlines()
+		a.checkLocales()
+		a.loadAssets()
 		a.generateMux()
 		a.generateIndexCache()
+		a.generateGroups()
 
 		a.auth_cache = make(map[*http.Request]AuthSession)
+
+		if a.hasRetention() {
+			go a.purgeTrash()
+		}
+
+		a.checkWebhooks()
+		if len(a.Webhooks) > 0 {
+			a.webhook_kick = make(chan bool, 1)
+			go a.deliverWebhooks()
+		}
 	})
 }
 
@@ -148,6 +211,18 @@ func (a *Admin) collFor(dbcoll string) *mgo.Collection {
 	return a.Session.DB(pieces[0]).C(pieces[1])
 }
 
+//Close stops the goroutines the admin runs in the background for purging the
+//trash, delivering webhooks and reloading templates in DevMode. The admin should
+//not be used after it is closed.
+func (a *Admin) Close() error {
+	a.closed.Do(func() {
+		if a.done != nil {
+			close(a.done)
+		}
+	})
+	return nil
+}
+
 //ServeHTTP lets *Admin conform to the http.Handler interface for use in web servers.
 func (a *Admin) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	a.init()
@@ -155,8 +230,8 @@ func (a *Admin) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	//strip off the prefix
 	req.URL.Path = req.URL.Path[len(a.Prefix):]
 
-	//if they're going to the auth handler, let them through
-	if a.Auth == nil || strings.HasPrefix(req.URL.Path, a.Routes["auth"]) {
+	//if they're going to the auth handler or for assets, let them through
+	if a.Auth == nil || strings.HasPrefix(req.URL.Path, a.Routes["auth"]) || a.isAssetRequest(req.URL.Path) {
 		a.server.ServeHTTP(w, req)
 		return
 	}
@@ -96,6 +96,20 @@ func TestAdminRegisterCustomLoader(t *testing.T) {
 	}
 }
 
+func TestAdminRegisterCodecTypes(t *testing.T) {
+	h := &Admin{
+		Renderer: &TestRenderer{},
+	}
+
+	defer func() {
+		if err := recover(); err != nil {
+			t.Fatal("Error registering a type with codecs:", err)
+		}
+	}()
+
+	h.Register(T8{}, "admin_test.T8", nil)
+}
+
 func TestAdminNewTypeNewInstance(t *testing.T) {
 	h := &Admin{
 		Renderer: &TestRenderer{},
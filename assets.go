@@ -0,0 +1,222 @@
+package admin
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//builtinAssets are the stylesheets and scripts the built in templates use.
+//
+//go:embed assets
+var builtinAssets embed.FS
+
+//assetMaxAge is how long browsers may cache an asset requested by its
+//fingerprinted url, which changes whenever the asset does.
+const assetMaxAge = 365 * 24 * time.Hour
+
+//asset is a file served on the assets route. url is its fingerprinted name,
+//which has the start of the hash of the contents before the extension, and
+//encodings maps a content encoding to the compressed contents.
+type asset struct {
+	name      string
+	url       string
+	hash      string
+	body      []byte
+	encodings map[string][]byte
+}
+
+//compressed are the content encodings assets may be precompressed with, in
+//order of preference, with the extension of their precompressed files.
+var compressed = []struct {
+	encoding string
+	ext      string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+//fingerprint returns the name with the hash inserted before the extension, so
+//that "admin.css" becomes "admin.0123456789.css".
+func fingerprint(name, hash string) string {
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hash[:10] + ext
+}
+
+//compressible returns if assets with the name are worth compressing.
+func compressible(name string) bool {
+	typ := mime.TypeByExtension(path.Ext(name))
+	return strings.HasPrefix(typ, "text/") ||
+		strings.Contains(typ, "javascript") ||
+		strings.Contains(typ, "json") ||
+		strings.Contains(typ, "svg")
+}
+
+//gzipped returns the data compressed with gzip.
+func gzipped(data []byte) []byte {
+	var buf bytes.Buffer
+	w, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+//loadAssets reads every file in the file system into assets keyed by their
+//name. Files ending in .br or .gz are the precompressed contents of the file
+//without the extension, and compressible files without a .gz get gzipped.
+func loadAssets(fsys fs.FS) (map[string]*asset, error) {
+	assets := map[string]*asset{}
+	variants := map[string]map[string][]byte{}
+
+	err := fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+
+		for _, c := range compressed {
+			if strings.HasSuffix(name, c.ext) {
+				orig := strings.TrimSuffix(name, c.ext)
+				if variants[orig] == nil {
+					variants[orig] = map[string][]byte{}
+				}
+				variants[orig][c.encoding] = data
+				return nil
+			}
+		}
+
+		sum := sha256.Sum256(data)
+		hash := hex.EncodeToString(sum[:])
+		assets[name] = &asset{
+			name:      name,
+			url:       fingerprint(name, hash),
+			hash:      hash,
+			body:      data,
+			encodings: map[string][]byte{},
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for name, a := range assets {
+		for encoding, data := range variants[name] {
+			a.encodings[encoding] = data
+		}
+		if _, ok := a.encodings["gzip"]; !ok && compressible(name) {
+			if data := gzipped(a.body); len(data) < len(a.body) {
+				a.encodings["gzip"] = data
+			}
+		}
+	}
+	return assets, nil
+}
+
+//loadAssets sets up the assets served by the admin: the built in ones, with
+//any in Assets added or replacing them by name.
+func (a *Admin) loadAssets() {
+	builtin, err := fs.Sub(builtinAssets, "assets")
+	if err != nil {
+		panic(err)
+	}
+	a.assets, err = loadAssets(builtin)
+	if err != nil {
+		panic("Error loading assets: " + err.Error())
+	}
+
+	if a.Assets != nil {
+		extra, err := loadAssets(a.Assets)
+		if err != nil {
+			panic("Error loading assets: " + err.Error())
+		}
+		for name, as := range extra {
+			a.assets[name] = as
+		}
+	}
+
+	a.asset_urls = make(map[string]*asset, len(a.assets))
+	for _, as := range a.assets {
+		a.asset_urls[as.url] = as
+	}
+}
+
+//isAssetRequest returns if the path is on the assets route, which is served
+//without logging in so the login page has its stylesheet.
+func (a *Admin) isAssetRequest(p string) bool {
+	route, ok := a.Routes["assets"]
+	return ok && route != "" && strings.HasPrefix(p, route)
+}
+
+//acceptsEncoding returns if the Accept-Encoding header of the request allows
+//the encoding.
+func acceptsEncoding(req *http.Request, encoding string) bool {
+	for _, part := range strings.Split(req.Header.Get("Accept-Encoding"), ",") {
+		fields := strings.Split(part, ";")
+		if strings.TrimSpace(fields[0]) != encoding {
+			continue
+		}
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil && q == 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
+
+//Serves the assets by their fingerprinted url with long lived cache headers, or
+//by their name for revalidating every time, in the best encoding the client
+//accepts.
+func (a *Admin) asset(w http.ResponseWriter, req *http.Request) {
+	name := strings.TrimPrefix(req.URL.Path, "/")
+
+	as, fingerprinted := a.asset_urls[name]
+	if !fingerprinted {
+		if as = a.assets[name]; as == nil {
+			a.Renderer.NotFound(w, req)
+			return
+		}
+	}
+
+	h := w.Header()
+	if fingerprinted {
+		h.Set("Cache-Control", "public, max-age="+strconv.Itoa(int(assetMaxAge/time.Second))+", immutable")
+	} else {
+		h.Set("Cache-Control", "no-cache")
+	}
+	if typ := mime.TypeByExtension(path.Ext(as.name)); typ != "" {
+		h.Set("Content-Type", typ)
+	}
+
+	body, etag := as.body, as.hash[:16]
+	if len(as.encodings) > 0 {
+		h.Add("Vary", "Accept-Encoding")
+		for _, c := range compressed {
+			if data, ok := as.encodings[c.encoding]; ok && acceptsEncoding(req, c.encoding) {
+				body, etag = data, etag+"-"+c.encoding
+				h.Set("Content-Encoding", c.encoding)
+				break
+			}
+		}
+	}
+	h.Set("ETag", `"`+etag+`"`)
+
+	http.ServeContent(w, req, as.name, time.Time{}, bytes.NewReader(body))
+}
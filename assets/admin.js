@@ -0,0 +1,29 @@
+// Autocompletes reference fields from the JSON lookup of the referenced
+// collection, offering the matching documents in a datalist.
+document.addEventListener("DOMContentLoaded", function () {
+	document.querySelectorAll("input.autocomplete[data-lookup]").forEach(function (input) {
+		var list = document.createElement("datalist");
+		list.id = input.id + ".lookup";
+		input.setAttribute("list", list.id);
+		input.parentNode.appendChild(list);
+
+		var pending;
+		input.addEventListener("input", function () {
+			clearTimeout(pending);
+			pending = setTimeout(function () {
+				var url = input.dataset.lookup + "?q=" + encodeURIComponent(input.value);
+				fetch(url, {credentials: "same-origin"}).then(function (resp) {
+					return resp.json();
+				}).then(function (results) {
+					list.textContent = "";
+					results.forEach(function (result) {
+						var option = document.createElement("option");
+						option.value = result.id;
+						option.label = result.label;
+						list.appendChild(option);
+					});
+				});
+			}, 200);
+		});
+	});
+});
@@ -0,0 +1,158 @@
+package admin
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFingerprint(t *testing.T) {
+	cases := map[string]string{
+		"admin.css":     "admin.0123456789.css",
+		"img/logo.png":  "img/logo.0123456789.png",
+		"jquery.min.js": "jquery.min.0123456789.js",
+		"LICENSE":       "LICENSE.0123456789",
+	}
+	for name, expected := range cases {
+		if got := fingerprint(name, "0123456789abcdef"); got != expected {
+			t.Errorf("%s: Expected %s. Got %s", name, expected, got)
+		}
+	}
+}
+
+func TestAcceptsEncoding(t *testing.T) {
+	cases := []struct {
+		header, encoding string
+		expected         bool
+	}{
+		{"", "gzip", false},
+		{"gzip", "gzip", true},
+		{"gzip, deflate, br", "br", true},
+		{"gzip;q=0", "gzip", false},
+		{"br;q=0.0, gzip;q=0.5", "br", false},
+		{"br;q=0.0, gzip;q=0.5", "gzip", true},
+		{"xgzip", "gzip", false},
+	}
+	for _, c := range cases {
+		req := &http.Request{Header: http.Header{"Accept-Encoding": {c.header}}}
+		if got := acceptsEncoding(req, c.encoding); got != c.expected {
+			t.Errorf("%q %s: Expected %v. Got %v", c.header, c.encoding, c.expected, got)
+		}
+	}
+}
+
+func TestLoadAssets(t *testing.T) {
+	css := strings.Repeat("body { color: red; }\n", 20)
+	assets, err := loadAssets(fstest.MapFS{
+		"site.css":     {Data: []byte(css)},
+		"site.css.br":  {Data: []byte("brotli")},
+		"logo.png":     {Data: []byte("not really a png")},
+		"app.js":       {Data: []byte(css)},
+		"app.js.gz":    {Data: []byte("precompressed")},
+		"orphan.js.br": {Data: []byte("nothing to compress")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(assets) != 3 {
+		t.Fatalf("Expected 3 assets. Got %v", assets)
+	}
+
+	site := assets["site.css"]
+	if string(site.encodings["br"]) != "brotli" || site.encodings["gzip"] == nil {
+		t.Errorf("Expected brotli and gzipped site.css. Got %v", site.encodings)
+	}
+	if !strings.HasPrefix(site.url, "site.") || site.url != fingerprint("site.css", site.hash) {
+		t.Errorf("Unexpected url %s", site.url)
+	}
+	if string(assets["app.js"].encodings["gzip"]) != "precompressed" {
+		t.Errorf("Expected the precompressed app.js. Got %v", assets["app.js"].encodings)
+	}
+	if len(assets["logo.png"].encodings) != 0 {
+		t.Errorf("Expected an uncompressed logo. Got %v", assets["logo.png"].encodings)
+	}
+}
+
+func TestAssetHandler(t *testing.T) {
+	tr := &TestRenderer{}
+	h := &Admin{Renderer: tr}
+	h.loadAssets()
+
+	css := h.assets["admin.css"]
+	if css == nil {
+		t.Fatal("Missing the built in admin.css")
+	}
+
+	get := func(path string, header http.Header) *httptest.ResponseRecorder {
+		w, req := httptest.NewRecorder(), httptest.NewRequest("GET", "/"+strings.TrimPrefix(path, "/"), nil)
+		req.Header = header
+		h.asset(w, req)
+		return w
+	}
+
+	//fingerprinted urls are cached forever
+	w := get(css.url, http.Header{})
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), css.body) {
+		t.Fatalf("Unexpected response %d: %s", w.Code, w.Body)
+	}
+	if cc := w.Header().Get("Cache-Control"); !strings.Contains(cc, "immutable") {
+		t.Errorf("Expected a long lived cache. Got %q", cc)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/css") {
+		t.Errorf("Unexpected content type %q", ct)
+	}
+
+	//plain names are revalidated
+	w = get("/admin.css", http.Header{})
+	if cc := w.Header().Get("Cache-Control"); cc != "no-cache" {
+		t.Errorf("Expected revalidation. Got %q", cc)
+	}
+
+	//compressed when accepted
+	w = get(css.url, http.Header{"Accept-Encoding": {"gzip"}})
+	if w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("Expected gzip. Got %v", w.Header())
+	}
+	r, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := ioutil.ReadAll(r); !bytes.Equal(body, css.body) {
+		t.Error("Gzipped body doesn't match")
+	}
+
+	//etags match for the same encoding
+	etag := w.Header().Get("ETag")
+	w = get(css.url, http.Header{"Accept-Encoding": {"gzip"}, "If-None-Match": {etag}})
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expected not modified. Got %d", w.Code)
+	}
+
+	get("/missing.css", http.Header{})
+	if last := tr.Last(); last.Type != "NotFound" {
+		t.Errorf("Expected a NotFound. Got %v", last)
+	}
+}
+
+func TestAssetOverride(t *testing.T) {
+	h := &Admin{Assets: fstest.MapFS{
+		"admin.css": {Data: []byte("body {}")},
+		"logo.svg":  {Data: []byte("<svg></svg>")},
+	}}
+	h.loadAssets()
+
+	if string(h.assets["admin.css"].body) != "body {}" {
+		t.Error("Expected Assets to replace the built in admin.css")
+	}
+	if h.assets["admin.js"] == nil || h.assets["logo.svg"] == nil {
+		t.Error("Expected the built in and added assets")
+	}
+	if h.asset_urls[h.assets["logo.svg"].url] == nil {
+		t.Error("Expected the fingerprinted url of the logo")
+	}
+}
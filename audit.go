@@ -0,0 +1,396 @@
+package admin
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"launchpad.net/mgo"
+	"launchpad.net/mgo/bson"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+//Actions recorded in an AuditEntry.
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+//ActionField is the Field of the Change naming the ObjectAction that was run.
+//Running an action is recorded as an ActionUpdate of the document, with that
+//Change ahead of the changes the action made.
+const ActionField = "_action"
+
+//SystemUser is the User of the entries for writes the admin makes on its own,
+//like purging expired documents from the trash.
+const SystemUser = "system"
+
+//Change is the before and after formatted value of a single field in an
+//AuditEntry. Field is the dot separated path to the field.
+type Change struct {
+	Field  string `json:"field"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+//AuditEntry records a single write made through the admin. User is the
+//username of the logged in user, is empty if the admin is not auth protected,
+//and is the SystemUser for writes without a request. Object is the formatted id
+//of the document written.
+type AuditEntry struct {
+	ID         bson.ObjectId `bson:"_id,omitempty" json:"-"`
+	User       string        `json:"user"`
+	Time       time.Time     `json:"time"`
+	Collection string        `json:"collection"`
+	Object     string        `json:"object"`
+	Action     string        `json:"action"`
+	Changes    []Change      `json:"changes"`
+}
+
+//AuditFilter selects AuditEntries. Empty fields match everything.
+type AuditFilter struct {
+	Collection string
+	Object     string
+	User       string
+	Action     string
+}
+
+//matches returns if the entry is selected by the filter.
+func (f AuditFilter) matches(e AuditEntry) bool {
+	return (f.Collection == "" || f.Collection == e.Collection) &&
+		(f.Object == "" || f.Object == e.Object) &&
+		(f.User == "" || f.User == e.User) &&
+		(f.Action == "" || f.Action == e.Action)
+}
+
+//query returns the mongo query for the entries selected by the filter.
+func (f AuditFilter) query() bson.M {
+	q := bson.M{}
+	for key, val := range map[string]string{
+		"collection": f.Collection,
+		"object":     f.Object,
+		"user":       f.User,
+		"action":     f.Action,
+	} {
+		if val != "" {
+			q[key] = val
+		}
+	}
+	return q
+}
+
+//AuditLog is a sink that AuditEntries are recorded to after every successful
+//create, update and delete made by the admin.
+type AuditLog interface {
+	Record(AuditEntry) error
+}
+
+//AuditSearcher is an AuditLog that can be browsed from the audit view in the
+//admin. Search returns the entries selected by the filter, newest first, after
+//skipping skip of them and returning at most limit, along with the total number
+//of selected entries.
+type AuditSearcher interface {
+	AuditLog
+	Search(f AuditFilter, skip, limit int) ([]AuditEntry, int, error)
+}
+
+//WriterAudit is an AuditLog that writes every entry as a line of JSON to W.
+type WriterAudit struct {
+	W  io.Writer
+	mu sync.Mutex
+}
+
+//Record implements the AuditLog interface.
+func (w *WriterAudit) Record(e AuditEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err = w.W.Write(append(data, '\n'))
+	return err
+}
+
+//FileAudit is an AuditSearcher that appends every entry as a line of JSON to
+//the file at Path.
+type FileAudit struct {
+	Path string
+	mu   sync.Mutex
+}
+
+//Record implements the AuditLog interface.
+func (f *FileAudit) Record(e AuditEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.OpenFile(f.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+//Search implements the AuditSearcher interface by reading the whole file.
+func (f *FileAudit) Search(filter AuditFilter, skip, limit int) ([]AuditEntry, int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.Open(f.Path)
+	if os.IsNotExist(err) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var e AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, 0, err
+		}
+		if filter.matches(e) {
+			entries = append(entries, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	//newest first
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+
+	total := len(entries)
+	if skip > total {
+		skip = total
+	}
+	entries = entries[skip:]
+	if limit < len(entries) {
+		entries = entries[:limit]
+	}
+	return entries, total, nil
+}
+
+//CollectionAudit is an AuditSearcher that inserts every entry as a document in
+//a mongo collection.
+type CollectionAudit struct {
+	Session    *mgo.Session
+	DB         string
+	Collection string
+}
+
+//coll returns the mgo.Collection for the audit log.
+func (c CollectionAudit) coll() *mgo.Collection {
+	return c.Session.DB(c.DB).C(c.Collection)
+}
+
+//Record implements the AuditLog interface.
+func (c CollectionAudit) Record(e AuditEntry) error {
+	return c.coll().Insert(e)
+}
+
+//Search implements the AuditSearcher interface.
+func (c CollectionAudit) Search(f AuditFilter, skip, limit int) ([]AuditEntry, int, error) {
+	query := c.coll().Find(f.query())
+	total, err := query.Count()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var entries []AuditEntry
+	iter := query.Sort(bson.M{"time": -1}).Skip(skip).Limit(limit).Iter()
+	for {
+		var e AuditEntry
+		if !iter.Next(&e) {
+			break
+		}
+		entries = append(entries, e)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+//formValues returns the values of the object as they are put in a
+//TemplateContext, respecting if the object is a Loader.
+func formValues(t Formable) (map[string]interface{}, error) {
+	if l, ok := t.(Loader); ok {
+		return l.GenerateValues(), nil
+	}
+	return CreateValues(t)
+}
+
+//flattenValues copies the values into flat, keyed by the dot separated path to
+//every value, formatting them as strings.
+func flattenValues(flat map[string]string, values map[string]interface{}, prefix string) {
+	for key, val := range values {
+		if nested, ok := val.(map[string]interface{}); ok {
+			flattenValues(flat, nested, prefix+key+".")
+			continue
+		}
+		flat[prefix+key] = fmt.Sprint(val)
+	}
+}
+
+//diffValues returns the Changes between the before and after values of an
+//object, sorted by field. Either may be nil, for creates and deletes.
+func diffValues(before, after map[string]interface{}) []Change {
+	b, a := map[string]string{}, map[string]string{}
+	flattenValues(b, before, "")
+	flattenValues(a, after, "")
+
+	var changes []Change
+	for field, val := range b {
+		if nval, ok := a[field]; !ok || nval != val {
+			changes = append(changes, Change{field, val, nval})
+		}
+	}
+	for field, val := range a {
+		if _, ok := b[field]; !ok {
+			changes = append(changes, Change{field, "", val})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+	return changes
+}
+
+//compareValues returns a Change for every field in either of the before and
+//after values of an object, sorted by field, including the unchanged ones.
+func compareValues(before, after map[string]interface{}) []Change {
+	b, a := map[string]string{}, map[string]string{}
+	flattenValues(b, before, "")
+	flattenValues(a, after, "")
+
+	var changes []Change
+	for field, val := range b {
+		changes = append(changes, Change{field, val, a[field]})
+	}
+	for field, val := range a {
+		if _, ok := b[field]; !ok {
+			changes = append(changes, Change{field, "", val})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+	return changes
+}
+
+//username returns the username of the logged in user for the request, or the
+//SystemUser if there is no request. Writes without a request happen in the
+//background, where the auth cache can't be read safely.
+func (a *Admin) username(req *http.Request) string {
+	if req == nil {
+		return SystemUser
+	}
+	return a.auth_cache[req].Username
+}
+
+//audit records the write to the object with the formatted id in the audit log,
+//if there is one, and sends it to any webhooks. Any extra changes are recorded
+//before the differences in the values. The write already happened, so errors
+//are only logged.
+func (a *Admin) audit(req *http.Request, action, coll, id string, before, after map[string]interface{}, extra ...Change) {
+	e := AuditEntry{
+		User:       a.username(req),
+		Time:       time.Now(),
+		Collection: coll,
+		Object:     id,
+		Action:     action,
+		Changes:    append(extra, diffValues(before, after)...),
+	}
+
+	if a.Audit != nil {
+		if err := a.Audit.Record(e); err != nil {
+			a.logger.Printf("Error recording audit entry: %s", err)
+		}
+	}
+
+	//deletes send what was deleted
+	values := after
+	if values == nil {
+		values = before
+	}
+	a.notify(e, values)
+}
+
+//Presents the audit log filtered to a collection and object from the path,
+//and a user and action from the query
+func (a *Admin) auditLog(w http.ResponseWriter, req *http.Request) {
+	coll, id := parseRequest(req.URL.Path)
+
+	//make sure the audit log can be browsed
+	searcher, ok := a.Audit.(AuditSearcher)
+	if !ok {
+		a.Renderer.NotFound(w, req)
+		return
+	}
+
+	//make sure we know about the requested collection
+	if coll != "" && !a.hasType(coll) {
+		a.Renderer.NotFound(w, req)
+		return
+	}
+
+	query := req.URL.Query()
+	filter := AuditFilter{
+		Collection: coll,
+		Object:     id,
+		User:       query.Get("user"),
+		Action:     query.Get("action"),
+	}
+
+	page, numpage := grabInt(query, "page", 1), grabInt(query, "numpage", 20)
+	if page < 1 {
+		page = 1
+	}
+	if numpage < 1 {
+		numpage = 1
+	}
+
+	entries, total, err := searcher.Search(filter, numpage*(page-1), numpage)
+	if err != nil {
+		a.Renderer.InternalError(w, req, err)
+		return
+	}
+
+	pages := (total + numpage - 1) / numpage
+	if pages < 1 {
+		pages = 1
+	}
+
+	a.renderAudit(w, req, AuditContext{
+		BaseContext: a.baseContext(req),
+		Filter:      filter,
+		Entries:     entries,
+		Pagination: Pagination{
+			Pages:       pages,
+			CurrentPage: page,
+			query:       query,
+		},
+	})
+}
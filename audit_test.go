@@ -0,0 +1,157 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffValues(t *testing.T) {
+	before := map[string]interface{}{
+		"X": "1",
+		"Y": "same",
+		"Z": map[string]interface{}{"A": "a", "B": "gone"},
+	}
+	after := map[string]interface{}{
+		"X": "2",
+		"Y": "same",
+		"Z": map[string]interface{}{"A": "b", "C": "new"},
+	}
+
+	expected := []Change{
+		{"X", "1", "2"},
+		{"Z.A", "a", "b"},
+		{"Z.B", "gone", ""},
+		{"Z.C", "", "new"},
+	}
+	if got := diffValues(before, after); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected: %v\nGot:      %v", expected, got)
+	}
+
+	if got := diffValues(nil, map[string]interface{}{"X": "1"}); !reflect.DeepEqual(got, []Change{{"X", "", "1"}}) {
+		t.Fatalf("Unexpected changes for a create: %v", got)
+	}
+}
+
+func TestCompareValues(t *testing.T) {
+	theirs := map[string]interface{}{
+		"X": "1",
+		"Z": map[string]interface{}{"A": "a"},
+	}
+	submitted := map[string]interface{}{
+		"X": "1",
+		"Y": "new",
+		"Z": map[string]interface{}{"A": "b"},
+	}
+
+	expected := []Change{
+		{"X", "1", "1"},
+		{"Y", "", "new"},
+		{"Z.A", "a", "b"},
+	}
+	if got := compareValues(theirs, submitted); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected: %v\nGot:      %v", expected, got)
+	}
+}
+
+func TestWriterAudit(t *testing.T) {
+	var buf bytes.Buffer
+	w := &WriterAudit{W: &buf}
+
+	e := AuditEntry{User: "bob", Collection: "admin_test.T", Object: "1", Action: ActionCreate}
+	if err := w.Record(e); err != nil {
+		t.Fatal(err)
+	}
+
+	var got AuditEntry
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, e) {
+		t.Fatalf("Expected %+v. Got %+v", e, got)
+	}
+}
+
+func TestFileAudit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "admin_audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	f := &FileAudit{Path: filepath.Join(dir, "audit.log")}
+	if entries, total, err := f.Search(AuditFilter{}, 0, 10); err != nil || total != 0 || len(entries) != 0 {
+		t.Fatalf("Expected no entries in a missing file. Got %v %d %v", entries, total, err)
+	}
+
+	now := time.Now()
+	for i, action := range []string{ActionCreate, ActionUpdate, ActionUpdate, ActionDelete} {
+		err := f.Record(AuditEntry{
+			User:       "bob",
+			Time:       now.Add(time.Duration(i) * time.Second),
+			Collection: "admin_test.T",
+			Object:     "1",
+			Action:     action,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, total, err := f.Search(AuditFilter{Object: "1"}, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 4 || len(entries) != 2 || entries[0].Action != ActionDelete || entries[1].Action != ActionUpdate {
+		t.Fatalf("Expected the newest two of four entries. Got %d %+v", total, entries)
+	}
+
+	entries, total, err = f.Search(AuditFilter{Action: ActionUpdate}, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(entries) != 1 || !entries[0].Time.Equal(now.Add(time.Second)) {
+		t.Fatalf("Expected the older update. Got %d %+v", total, entries)
+	}
+
+	if _, total, _ := f.Search(AuditFilter{User: "alice"}, 0, 10); total != 0 {
+		t.Fatalf("Expected no entries for alice. Got %d", total)
+	}
+}
+
+func TestAuditExtraChanges(t *testing.T) {
+	var buf bytes.Buffer
+	h := &Admin{Audit: &WriterAudit{W: &buf}}
+
+	h.audit(nil, ActionUpdate, "admin_test.T", "1",
+		map[string]interface{}{"Status": "draft"},
+		map[string]interface{}{"Status": "published"},
+		Change{Field: ActionField, After: "publish"})
+
+	var got AuditEntry
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	expected := []Change{{Field: ActionField, After: "publish"}, {Field: "Status", Before: "draft", After: "published"}}
+	if got.Action != ActionUpdate || !reflect.DeepEqual(got.Changes, expected) {
+		t.Fatalf("Expected an update with %v. Got %+v", expected, got)
+	}
+}
+
+func TestUsername(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/", nil)
+	h := &Admin{auth_cache: map[*http.Request]AuthSession{req: {Username: "bob"}}}
+
+	if u := h.username(req); u != "bob" {
+		t.Errorf("Expected the logged in user. Got %q", u)
+	}
+	if u := h.username(nil); u != SystemUser {
+		t.Errorf("Expected the system user without a request. Got %q", u)
+	}
+}
@@ -11,11 +11,13 @@ type Authorizer interface {
 //of the authorization request. If Passed is true, Error must be "". Key is an
 //object that will be passed into future contexts that allows you to identify
 //which user is logged in. Username is the display username so you don't have
-//to query the database every time.
+//to query the database every time. Locale is the Tag of the Locale the user
+//prefers the admin in, or empty to use the Accept-Language header.
 type AuthResponse struct {
 	Passed   bool
 	Error    string
 	Username string
+	Locale   string
 
 	//Key must be marshallable by the json package
 	Key interface{}
@@ -11,6 +11,7 @@ import (
 type AuthSession struct {
 	Username string
 	Key      interface{}
+	Locale   string
 }
 
 func (a *AuthSession) add(s sign.Signer, w http.ResponseWriter) error {
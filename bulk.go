@@ -0,0 +1,172 @@
+package admin
+
+import (
+	"fmt"
+	"launchpad.net/mgo"
+	"launchpad.net/mgo/bson"
+	"net/http"
+	"reflect"
+)
+
+//ActionContext is passed to actions with the collection being acted on, the
+//request that triggered the action and the logged in user, if any.
+type ActionContext struct {
+	Collection string
+	C          *mgo.Collection
+	Request    *http.Request
+	Auth       *AuthSession
+}
+
+//BulkAction is an action run on many selected documents from the list view.
+//Name is submitted in the _action parameter and Label is shown to the user. Run
+//is passed the formatted ids of the selected documents.
+type BulkAction struct {
+	Name  string
+	Label string
+	Run   func(ctx ActionContext, ids []string) error
+}
+
+//bulkDelete is the name of the built in bulk action deleting the selected
+//documents.
+const bulkDelete = "delete"
+
+//checkBulkActions panics if the bulk actions in the options have missing or
+//duplicate names.
+func checkBulkActions(typ reflect.Type, opt *Options) {
+	seen := map[string]bool{bulkDelete: true}
+	for _, action := range opt.BulkActions {
+		if action.Name == "" || action.Run == nil {
+			panic(fmt.Sprintf("Bulk action %q on type %s needs a Name and Run", action.Label, typ))
+		}
+		if seen[action.Name] {
+			panic(fmt.Sprintf("Duplicate bulk action %s on type %s", action.Name, typ))
+		}
+		seen[action.Name] = true
+	}
+}
+
+//bulkActions returns every bulk action available on the collection, starting
+//with the built in delete.
+func (a *Admin) bulkActions(coll string) []BulkAction {
+	actions := []BulkAction{{
+		Name:  bulkDelete,
+		Label: "Delete selected",
+		Run: func(ctx ActionContext, ids []string) error {
+			return a.deleteAll(ctx.Request, coll, ids)
+		},
+	}}
+	return append(actions, a.types[coll].Options.BulkActions...)
+}
+
+//actionContext returns the ActionContext for acting on the collection.
+func (a *Admin) actionContext(req *http.Request, coll string) ActionContext {
+	return ActionContext{
+		Collection: coll,
+		C:          a.collFor(coll),
+		Request:    req,
+		Auth:       a.baseContext(req).Auth,
+	}
+}
+
+//deleteAll deletes every document with the formatted ids in the collection,
+//reporting how many could not be deleted.
+func (a *Admin) deleteAll(req *http.Request, coll string, ids []string) error {
+	var failed int
+	var last error
+	for _, id := range ids {
+		err := a.deleteId(req, coll, id)
+		if err != nil {
+			failed, last = failed+1, err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("Unable to delete %d of %d: %s", failed, len(ids), last)
+	}
+	return nil
+}
+
+//deleteId loads and deletes the document with the formatted id.
+func (a *Admin) deleteId(req *http.Request, coll, id string) error {
+	q, err := a.idQuery(coll, id)
+	if err != nil {
+		return err
+	}
+
+	t := a.newType(coll)
+	if err := a.collFor(coll).Find(a.liveQuery(coll, bson.M{"_id": q})).One(t); err != nil {
+		return err
+	}
+	return a.deleteObject(req, coll, id, t)
+}
+
+//allIds returns the formatted ids of every document shown by the list view of
+//the collection, on any page.
+func (a *Admin) allIds(coll string) ([]string, error) {
+	var ids []string
+	iter := a.collFor(coll).Find(a.liveQuery(coll, nil)).Select(bson.M{"_id": 1}).Iter()
+	for {
+		var doc struct {
+			ID interface{} `bson:"_id"`
+		}
+		if !iter.Next(&doc) {
+			break
+		}
+		ids = append(ids, formatValue(reflect.ValueOf(doc.ID)))
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+//Presents the confirmation for a bulk action posted to the list view, and runs
+//it when _sure=yes is posted as well. The documents are selected by the
+//_selected parameters, or every document in the list if _all=yes
+func (a *Admin) bulk(w http.ResponseWriter, req *http.Request, coll string) {
+	req.ParseForm()
+
+	//find the requested action
+	var action BulkAction
+	var found bool
+	for _, ba := range a.bulkActions(coll) {
+		if ba.Name == req.Form.Get("_action") {
+			action, found = ba, true
+			break
+		}
+	}
+	if !found {
+		a.Renderer.NotFound(w, req)
+		return
+	}
+
+	//grab the selection
+	all, ids := req.Form.Get("_all") == "yes", req.Form["_selected"]
+	if all {
+		var err error
+		if ids, err = a.allIds(coll); err != nil {
+			a.Renderer.InternalError(w, req, err)
+			return
+		}
+	}
+
+	var attempted, success bool
+	var err error
+	if len(ids) == 0 {
+		err = fmt.Errorf("Nothing selected")
+	} else if req.Form.Get("_sure") == "yes" {
+		attempted = true
+		err = action.Run(a.actionContext(req, coll), ids)
+		success = err == nil
+	}
+
+	a.renderBulk(w, req, BulkContext{
+		BaseContext: a.baseContext(req),
+		Collection:  coll,
+		Action:      action,
+		IDs:         ids,
+		All:         all,
+		Attempted:   attempted,
+		Success:     success,
+		Error:       err,
+	})
+}
@@ -0,0 +1,42 @@
+package admin
+
+import (
+	"testing"
+)
+
+func TestBulkActions(t *testing.T) {
+	h := &Admin{}
+	h.Register(T12{}, "admin_test.T12", &Options{
+		BulkActions: []BulkAction{{
+			Name:  "publish",
+			Label: "Publish selected",
+			Run:   func(ActionContext, []string) error { return nil },
+		}},
+	})
+
+	actions := h.bulkActions("admin_test.T12")
+	if len(actions) != 2 || actions[0].Name != bulkDelete || actions[1].Name != "publish" {
+		t.Fatalf("Expected delete and publish. Got %v", actions)
+	}
+}
+
+func TestCheckBulkActions(t *testing.T) {
+	run := func(ActionContext, []string) error { return nil }
+	cases := [][]BulkAction{
+		{{Name: "", Run: run}},
+		{{Name: "publish"}},
+		{{Name: "publish", Run: run}, {Name: "publish", Run: run}},
+		{{Name: bulkDelete, Run: run}},
+	}
+	for _, actions := range cases {
+		func() {
+			defer func() {
+				if err := recover(); err == nil {
+					t.Errorf("No panic with %v", actions)
+				}
+			}()
+			h := &Admin{}
+			h.Register(T12{}, "admin_test.T12", &Options{BulkActions: actions})
+		}()
+	}
+}
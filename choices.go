@@ -0,0 +1,70 @@
+package admin
+
+import (
+	"github.com/zeebo/admin/forms"
+	"reflect"
+	"strings"
+)
+
+//chooserFor returns the object as a forms.Chooser if it is one, and nil
+//otherwise.
+func chooserFor(obj interface{}) forms.Chooser {
+	ch, _ := obj.(forms.Chooser)
+	return ch
+}
+
+//choicesFor returns the fixed choices for the field with the dot separated path
+//name. Choices come from the object's Choices method if it is a forms.Chooser
+//and returns a non nil slice for the field, and otherwise from a choices tag on
+//the field. The tag is a comma separated list of values with optional labels
+//after a colon, e.g.
+//
+//	Status string `choices:"draft:Draft,published:Published,archived"`
+//
+//Fields without choices return nil.
+func choicesFor(ch forms.Chooser, field reflect.StructField, name string) []forms.Item {
+	if ch != nil {
+		if items := ch.Choices(name); items != nil {
+			return items
+		}
+	}
+	return parseChoices(field.Tag.Get("choices"))
+}
+
+//parseChoices parses the value of a choices tag into a slice of forms.Items.
+func parseChoices(tag string) []forms.Item {
+	if tag == "" {
+		return nil
+	}
+
+	var items []forms.Item
+	for _, choice := range strings.Split(tag, ",") {
+		value, label := choice, choice
+		if i := strings.Index(choice, ":"); i >= 0 {
+			value, label = choice[:i], choice[i+1:]
+		}
+		items = append(items, forms.Item{Label: label, Value: value})
+	}
+	return items
+}
+
+//isChoice returns if the value is one of the choices.
+func isChoice(items []forms.Item, value string) bool {
+	for _, item := range items {
+		if item.Value == value {
+			return true
+		}
+	}
+	return false
+}
+
+//choiceLabel returns the label for the value in the choices, or the value if
+//it is not one of the choices.
+func choiceLabel(items []forms.Item, value string) string {
+	for _, item := range items {
+		if item.Value == value {
+			return item.Label
+		}
+	}
+	return value
+}
@@ -0,0 +1,77 @@
+package admin
+
+import (
+	"github.com/zeebo/admin/forms"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseChoices(t *testing.T) {
+	table := []struct {
+		tag      string
+		expected []forms.Item
+	}{
+		{"", nil},
+		{"a", []forms.Item{{Label: "a", Value: "a"}}},
+		{"a:A,b", []forms.Item{{Label: "A", Value: "a"}, {Label: "b", Value: "b"}}},
+		{"a:A:B", []forms.Item{{Label: "A:B", Value: "a"}}},
+	}
+
+	for _, c := range table {
+		if got := parseChoices(c.tag); !reflect.DeepEqual(got, c.expected) {
+			t.Errorf("%q: Expected %v. Got %v", c.tag, c.expected, got)
+		}
+	}
+}
+
+func TestLoadChoices(t *testing.T) {
+	type F []string
+
+	table := []struct {
+		data url.Values
+		errs F
+	}{
+		{url.Values{"Status": {"draft"}, "Level": {"2"}, "Inner.Color": {"red"}}, F{}},
+		{url.Values{"Status": {"Draft"}}, F{"Status"}},
+		{url.Values{"Level": {"3"}}, F{"Level"}},
+		{url.Values{"Status": {""}, "Inner.Color": {"green"}}, F{"Status", "Inner.Color"}},
+	}
+
+	for _, c := range table {
+		var x T10
+		errs, err := Load(c.data, &x)
+		if err != nil {
+			t.Errorf("Error while loading %v:\n%s", c.data, err)
+		}
+		if !compareErrs(errs, c.errs) {
+			t.Errorf("Errors did not agree.\nExpected: %v\nGot %v", c.errs, errs)
+		}
+	}
+}
+
+func TestGenerateFormChoices(t *testing.T) {
+	ctx := TemplateContext{
+		Values: map[string]interface{}{
+			"Status": "published",
+			"Level":  "1",
+			"Inner":  map[string]interface{}{"Color": "blue"},
+		},
+	}
+
+	out, err := GenerateForm(T10{}, ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{
+		`<option value="published" selected>Published</option>`,
+		`<option value="1" selected>Low</option>`,
+		`<input type="radio" name="Inner.Color" value="blue" checked> blue`,
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("Expected %q in output.\nGot: %s", s, out)
+		}
+	}
+}
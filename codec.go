@@ -0,0 +1,275 @@
+package admin
+
+import (
+	"encoding"
+	"encoding/hex"
+	"fmt"
+	"github.com/zeebo/admin/forms"
+	"launchpad.net/mgo/bson"
+	"reflect"
+	"sync"
+	"time"
+)
+
+//Codec is a type that knows how to convert values of a single type to and from
+//the strings used by forms and the list view. Format is passed the value (never
+//a pointer to it) and must return the string to display. Parse is passed the
+//string submitted in a form and must return a value of the type the Codec was
+//registered for. Widget returns the kind of form field used to edit the value.
+type Codec interface {
+	Format(interface{}) string
+	Parse(string) (interface{}, error)
+	Widget() forms.Field
+}
+
+//FieldCodec is implemented by types that know how to represent themselves in
+//forms. It is the per type alternative to registering a Codec, and is useful
+//for domain types such as money or phone numbers. ParseField is usually
+//implemented on the pointer to the type, e.g.
+//
+//	type Money int64
+//
+//	func (m Money) FormatField() string          { ... }
+//	func (m *Money) ParseField(data string) error { ... }
+//	func (m Money) FieldWidget() forms.Field     { return forms.Text }
+type FieldCodec interface {
+	FormatField() string
+	ParseField(string) error
+	FieldWidget() forms.Field
+}
+
+//fieldCodecType and friends are the reflect.Types of the interfaces we check for
+//when looking up the Codec for a type.
+var (
+	fieldCodecType      = reflect.TypeOf((*FieldCodec)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+//codecs is the registry of Codecs keyed by the type they handle.
+var (
+	codecs   = map[reflect.Type]Codec{}
+	codecsMu sync.RWMutex
+)
+
+//RegisterCodec registers the Codec to be used for every value with the same
+//type as the passed in value. Pointers are walked up so that
+//
+//	RegisterCodec(time.Time{}, c)
+//	RegisterCodec(new(time.Time), c)
+//
+//are equivalent. Registering a Codec for a type that already has one replaces
+//the old Codec. Types with a Codec are treated as basic types by Load,
+//CreateValues and CreateEmptyValues, so struct types like time.Time are not
+//recursed into.
+func RegisterCodec(typ interface{}, c Codec) {
+	if typ == nil || c == nil {
+		panic("RegisterCodec called with a nil type or Codec")
+	}
+
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[indirectType(reflect.TypeOf(typ))] = c
+}
+
+//codecFor returns the Codec for the type, if any. Registered Codecs take
+//precedence, followed by types implementing FieldCodec, followed by types
+//implementing both encoding.TextMarshaler and encoding.TextUnmarshaler.
+func codecFor(typ reflect.Type) (Codec, bool) {
+	codecsMu.RLock()
+	c, ok := codecs[typ]
+	codecsMu.RUnlock()
+	if ok {
+		return c, true
+	}
+
+	ptr := reflect.PtrTo(typ)
+	switch {
+	case ptr.Implements(fieldCodecType):
+		return fieldCodec{typ}, true
+	case ptr.Implements(textMarshalerType) && ptr.Implements(textUnmarshalerType):
+		return textCodec{typ}, true
+	}
+	return nil, false
+}
+
+//addressable returns a pointer to a copy of the value so that methods with
+//pointer receivers can be called on it.
+func addressable(typ reflect.Type, v interface{}) interface{} {
+	ptr := reflect.New(typ)
+	ptr.Elem().Set(reflect.ValueOf(v))
+	return ptr.Interface()
+}
+
+//isBasic returns if the type should be treated as a single value by the loading
+//engine instead of being recursed into.
+func isBasic(typ reflect.Type) bool {
+	if typ.Kind() != reflect.Struct {
+		return true
+	}
+	_, ok := codecFor(typ)
+	return ok
+}
+
+//formatValue returns the string representation of a value for display. It uses
+//the Codec for the type if one exists, the Hex() method for hexable types, and
+//falls back to fmt.Sprint. Nil pointers and interfaces are the empty string.
+func formatValue(val reflect.Value) string {
+	v, err := indirect(val)
+	if err != nil {
+		return ""
+	}
+	if val = v; !val.CanInterface() {
+		return ""
+	}
+
+	if c, ok := codecFor(val.Type()); ok {
+		return c.Format(val.Interface())
+	}
+
+	switch item := val.Interface().(type) {
+	case hexable:
+		return item.Hex()
+	}
+	return fmt.Sprint(val.Interface())
+}
+
+//parseCodec parses the data with the Codec and sets it into the value.
+func parseCodec(c Codec, val reflect.Value, data string) error {
+	v, err := c.Parse(data)
+	if err != nil {
+		return err
+	}
+
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() || !rv.Type().AssignableTo(val.Type()) {
+		return fmt.Errorf("Codec for %s returned a %T", val.Type(), v)
+	}
+
+	val.Set(rv)
+	return nil
+}
+
+//fieldCodec adapts a type implementing FieldCodec to the Codec interface.
+type fieldCodec struct {
+	typ reflect.Type
+}
+
+func (f fieldCodec) Format(v interface{}) string {
+	return addressable(f.typ, v).(FieldCodec).FormatField()
+}
+
+func (f fieldCodec) Parse(data string) (interface{}, error) {
+	ptr := reflect.New(f.typ)
+	if err := ptr.Interface().(FieldCodec).ParseField(data); err != nil {
+		return nil, err
+	}
+	return ptr.Elem().Interface(), nil
+}
+
+func (f fieldCodec) Widget() forms.Field {
+	return reflect.New(f.typ).Interface().(FieldCodec).FieldWidget()
+}
+
+//textCodec adapts a type implementing encoding.TextMarshaler and
+//encoding.TextUnmarshaler to the Codec interface. It is always edited with a
+//text field.
+type textCodec struct {
+	typ reflect.Type
+}
+
+func (t textCodec) Format(v interface{}) string {
+	data, err := addressable(t.typ, v).(encoding.TextMarshaler).MarshalText()
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func (t textCodec) Parse(data string) (interface{}, error) {
+	ptr := reflect.New(t.typ)
+	if err := ptr.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(data)); err != nil {
+		return nil, err
+	}
+	return ptr.Elem().Interface(), nil
+}
+
+func (t textCodec) Widget() forms.Field { return forms.Text }
+
+func init() {
+	RegisterCodec(time.Time{}, timeCodec{})
+	RegisterCodec(time.Duration(0), durationCodec{})
+	RegisterCodec(bson.ObjectId(""), objectIdCodec{})
+}
+
+//timeLayouts are the layouts accepted when parsing a time.Time, in order. They
+//cover RFC3339 and the values sent by date and datetime-local inputs.
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+//timeCodec is the built in Codec for time.Time values. The zero time is
+//represented by the empty string.
+type timeCodec struct{}
+
+func (timeCodec) Format(v interface{}) string {
+	t := v.(time.Time)
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func (timeCodec) Parse(data string) (interface{}, error) {
+	if data == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, data); err == nil {
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("Invalid time: %q", data)
+}
+
+func (timeCodec) Widget() forms.Field { return forms.DateTime }
+
+//durationCodec is the built in Codec for time.Duration values using the
+//format understood by time.ParseDuration.
+type durationCodec struct{}
+
+func (durationCodec) Format(v interface{}) string {
+	return v.(time.Duration).String()
+}
+
+func (durationCodec) Parse(data string) (interface{}, error) {
+	if data == "" {
+		return time.Duration(0), nil
+	}
+	return time.ParseDuration(data)
+}
+
+func (durationCodec) Widget() forms.Field { return forms.Text }
+
+//objectIdCodec is the built in Codec for bson.ObjectId values. They are
+//represented by their hex value, and the empty string is the empty id.
+type objectIdCodec struct{}
+
+func (objectIdCodec) Format(v interface{}) string {
+	return v.(bson.ObjectId).Hex()
+}
+
+func (objectIdCodec) Parse(data string) (interface{}, error) {
+	if data == "" {
+		return bson.ObjectId(""), nil
+	}
+	if _, err := hex.DecodeString(data); err != nil || len(data) != 24 {
+		return nil, fmt.Errorf("Invalid ObjectId: %q", data)
+	}
+	return bson.ObjectIdHex(data), nil
+}
+
+func (objectIdCodec) Widget() forms.Field { return forms.Text }
@@ -0,0 +1,168 @@
+package admin
+
+import (
+	"launchpad.net/mgo/bson"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoadTime(t *testing.T) {
+	var x struct {
+		X time.Time
+		Y *time.Time
+	}
+
+	table := []struct {
+		data     string
+		expected time.Time
+	}{
+		{"2012-01-02T15:04:05Z", time.Date(2012, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"2012-01-02T15:04", time.Date(2012, 1, 2, 15, 4, 0, 0, time.UTC)},
+		{"2012-01-02", time.Date(2012, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"", time.Time{}},
+	}
+
+	for _, c := range table {
+		errs, err := Load(url.Values{"X": {c.data}, "Y": {c.data}}, &x)
+		if err != nil || len(errs) > 0 {
+			t.Fatalf("Error loading %q: %v %v", c.data, err, errs)
+		}
+		if !x.X.Equal(c.expected) || !x.Y.Equal(c.expected) {
+			t.Errorf("Expected %v. Got %v and %v", c.expected, x.X, *x.Y)
+		}
+	}
+
+	errs, err := Load(url.Values{"X": {"yesterday"}}, &x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !compareErrs(errs, []string{"X"}) {
+		t.Fatalf("Expected an error loading an invalid time. Got %v", errs)
+	}
+}
+
+func TestLoadDuration(t *testing.T) {
+	var x struct {
+		X time.Duration
+	}
+
+	if _, err := Load(url.Values{"X": {"1h30m"}}, &x); err != nil {
+		t.Fatal(err)
+	}
+	if x.X != 90*time.Minute {
+		t.Fatalf("Expected %v. Got %v", 90*time.Minute, x.X)
+	}
+}
+
+func TestLoadObjectId(t *testing.T) {
+	var x struct {
+		X bson.ObjectId
+	}
+
+	if _, err := Load(url.Values{"X": {"4f07c34779bf562daff8640c"}}, &x); err != nil {
+		t.Fatal(err)
+	}
+	if x.X != bson.ObjectIdHex("4f07c34779bf562daff8640c") {
+		t.Fatalf("Expected %q. Got %q", "4f07c34779bf562daff8640c", x.X.Hex())
+	}
+
+	errs, err := Load(url.Values{"X": {"not an id"}}, &x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !compareErrs(errs, []string{"X"}) {
+		t.Fatalf("Expected an error loading an invalid id. Got %v", errs)
+	}
+}
+
+func TestFormatValueNil(t *testing.T) {
+	var x struct {
+		Customer *bson.ObjectId
+		Any      interface{}
+	}
+
+	val := reflect.ValueOf(&x).Elem()
+	for i := 0; i < val.NumField(); i++ {
+		if got := formatValue(val.Field(i)); got != "" {
+			t.Errorf("Expected nothing for a nil %s. Got %q", val.Type().Field(i).Name, got)
+		}
+	}
+
+	id := bson.ObjectIdHex("4f07c34779bf562daff8640c")
+	x.Customer = &id
+	if got := formatValue(val.Field(0)); got != id.Hex() {
+		t.Errorf("Expected %q. Got %q", id.Hex(), got)
+	}
+}
+
+func TestCreateValuesCodecs(t *testing.T) {
+	type codecs struct {
+		X time.Time
+		Y time.Duration
+		Z bson.ObjectId
+	}
+
+	x := codecs{
+		X: time.Date(2012, 1, 2, 15, 4, 5, 0, time.UTC),
+		Y: 90 * time.Minute,
+		Z: bson.ObjectIdHex("4f07c34779bf562daff8640c"),
+	}
+	expected := map[string]interface{}{
+		"X": "2012-01-02T15:04:05Z",
+		"Y": "1h30m0s",
+		"Z": "4f07c34779bf562daff8640c",
+	}
+
+	ret, err := CreateValues(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ret, expected) {
+		t.Fatalf("Expected: %v\nGot:      %v", expected, ret)
+	}
+
+	ret, err = CreateEmptyValues(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ret, map[string]interface{}{"X": "", "Y": "", "Z": ""}) {
+		t.Fatalf("Expected empty values. Got %v", ret)
+	}
+}
+
+func TestLoadCustomCodecs(t *testing.T) {
+	var x T8
+
+	errs, err := Load(url.Values{"Price": {"12.34"}, "Tags": {"a,b"}}, &x)
+	if err != nil || len(errs) > 0 {
+		t.Fatalf("Error loading: %v %v", err, errs)
+	}
+	if x.Price != 1234 {
+		t.Errorf("Expected %d. Got %d", 1234, x.Price)
+	}
+	if !reflect.DeepEqual(x.Tags, Tags{"a", "b"}) {
+		t.Errorf("Expected %v. Got %v", Tags{"a", "b"}, x.Tags)
+	}
+
+	errs, err = Load(url.Values{"Price": {"twelve"}}, &x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !compareErrs(errs, []string{"Price"}) {
+		t.Fatalf("Expected an error loading invalid money. Got %v", errs)
+	}
+}
+
+func TestCreateValuesCustomCodecs(t *testing.T) {
+	x := T8{Price: 1234, Tags: Tags{"a", "b"}}
+
+	ret, err := CreateValues(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret["Price"] != "12.34" || ret["Tags"] != "a,b" || ret["When"] != "" {
+		t.Fatalf("Unexpected values: %v", ret)
+	}
+}
@@ -0,0 +1,142 @@
+package admin
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"launchpad.net/mgo/bson"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+//etagKey is the form key carrying the etag of the object when its update form
+//was rendered.
+const etagKey = "_etag"
+
+//checkVersionField panics if the VersionField in the options is not an integer
+//field on the type.
+func checkVersionField(typ reflect.Type, opt *Options) {
+	if opt.VersionField == "" {
+		return
+	}
+	ftyp, ok := fieldType(typ, opt.VersionField)
+	if !ok {
+		panic(fmt.Sprintf("Can't find a field named %s on type %s for the version", opt.VersionField, typ))
+	}
+	switch indirectType(ftyp).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+	default:
+		panic(fmt.Sprintf("Version field %s on type %s is not an integer", opt.VersionField, typ))
+	}
+}
+
+//etag returns a string that changes whenever the object is saved: the value of
+//the collection's VersionField, or a hash of the object's values. A nil version
+//is version 0.
+func (a *Admin) etag(coll string, t Formable) (string, error) {
+	if name := a.types[coll].Options.VersionField; name != "" {
+		if v := referenceId(t, name); v != "" {
+			return v, nil
+		}
+		return "0", nil
+	}
+
+	values, err := formValues(t)
+	if err != nil {
+		return "", err
+	}
+	flat := map[string]string{}
+	flattenValues(flat, values, "")
+
+	keys := make([]string, 0, len(flat))
+	for key := range flat {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	h := sha1.New()
+	for _, key := range keys {
+		io.WriteString(h, strconv.Quote(key)+"="+strconv.Quote(flat[key])+"\n")
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+//staleEtag returns if the etag sent with the update form doesn't match the etag
+//of the object as it is now. A missing etag only skips the check for
+//collections without a VersionField, since versioned updates must know the
+//version they were made from.
+func (a *Admin) staleEtag(coll, sent, etag string) bool {
+	if sent == "" {
+		return a.types[coll].Options.VersionField != ""
+	}
+	return sent != etag
+}
+
+//matchQuery returns the query matching the document with the id only if it is
+//unchanged since it was loaded into the object. That is a matching VersionField
+//if the collection has one, and every field the type models otherwise.
+func (a *Admin) matchQuery(coll string, id interface{}, t Formable) (bson.M, error) {
+	info, q := a.types[coll], bson.M{"_id": id}
+	val, err := indirect(reflect.ValueOf(t))
+	if err != nil {
+		return nil, err
+	}
+
+	//documents saved before they had a version match as version 0
+	if name := info.Options.VersionField; name != "" {
+		key, v := bsonPath(info.Type, name), storedValue(val, name)
+		if v == nil || reflect.ValueOf(v).Int() == 0 {
+			q["$or"] = []bson.M{{key: 0}, {key: nil}, {key: bson.M{"$exists": false}}}
+		} else {
+			q[key] = v
+		}
+		return q, nil
+	}
+
+	//a nil value matches fields that aren't stored
+	values, err := a.storedValues(coll, t)
+	if err != nil {
+		return nil, err
+	}
+	for key, v := range values {
+		q[key] = v
+	}
+	return q, nil
+}
+
+//storedValue returns the value of the field at the dot separated path in the
+//struct value, or nil if it is behind a nil pointer.
+func storedValue(val reflect.Value, path string) interface{} {
+	field, ok := fieldByPath(val, path)
+	if !ok {
+		return nil
+	}
+	field, err := indirect(field)
+	if err != nil {
+		return nil
+	}
+	return field.Interface()
+}
+
+//bumpVersion sets the VersionField of the object, if the collection has one, to
+//one more than the version in the etag the object was loaded with.
+func (a *Admin) bumpVersion(coll string, t Formable, etag string) error {
+	name := a.types[coll].Options.VersionField
+	if name == "" {
+		return nil
+	}
+
+	var n int64
+	if etag != "" {
+		var err error
+		if n, err = strconv.ParseInt(etag, 10, 64); err != nil {
+			return err
+		}
+	}
+	field, ok := fieldByPath(reflect.ValueOf(t), name)
+	if !ok {
+		return fmt.Errorf("Can't set the version %s on %s", name, coll)
+	}
+	return loadInto(alloc(field), strconv.FormatInt(n+1, 10))
+}
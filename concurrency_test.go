@@ -0,0 +1,190 @@
+package admin
+
+import (
+	"launchpad.net/mgo/bson"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestEtag(t *testing.T) {
+	h := &Admin{}
+	h.Register(T12{}, "admin_test.T12", nil)
+	h.Register(T10{}, "admin_test.T10", &Options{VersionField: "Level"})
+
+	x := &T12{Name: "foo"}
+	one, err := h.etag("admin_test.T12", x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	two, _ := h.etag("admin_test.T12", x)
+	if one == "" || one != two {
+		t.Fatalf("Expected a stable etag. Got %q and %q", one, two)
+	}
+
+	x.Inner.Note = "changed"
+	if three, _ := h.etag("admin_test.T12", x); three == one {
+		t.Fatal("Etag didn't change with the values")
+	}
+
+	if etag, _ := h.etag("admin_test.T10", &T10{Level: 7}); etag != "7" {
+		t.Fatalf("Expected the version as the etag. Got %q", etag)
+	}
+}
+
+func TestMatchQuery(t *testing.T) {
+	h := &Admin{}
+	h.Register(T12{}, "admin_test.T12", nil)
+	h.Register(T10{}, "admin_test.T10", &Options{VersionField: "Level"})
+
+	id := bson.ObjectIdHex("4f07c34779bf562daff8640c")
+	x := &T12{Rev: 2}
+	x.Inner.Note = "note"
+
+	//Name is empty with omitempty so it isn't stored
+	expected := bson.M{"_id": id, "name": nil, "rev": 2, "inner.note": "note"}
+	if q, err := h.matchQuery("admin_test.T12", id, x); err != nil || !reflect.DeepEqual(q, expected) {
+		t.Fatalf("Expected %v. Got %v %v", expected, q, err)
+	}
+
+	expected = bson.M{"_id": id, "level": 3}
+	if q, _ := h.matchQuery("admin_test.T10", id, &T10{Level: 3}); !reflect.DeepEqual(q, expected) {
+		t.Fatalf("Expected %v. Got %v", expected, q)
+	}
+
+	expected = bson.M{"_id": id, "$or": []bson.M{{"level": 0}, {"level": nil}, {"level": bson.M{"$exists": false}}}}
+	if q, _ := h.matchQuery("admin_test.T10", id, &T10{}); !reflect.DeepEqual(q, expected) {
+		t.Fatalf("Expected %v. Got %v", expected, q)
+	}
+}
+
+func TestNilVersion(t *testing.T) {
+	h := &Admin{}
+	h.Register(T16{}, "admin_test.T16", &Options{VersionField: "Ver"})
+
+	x := &T16{}
+	etag, err := h.etag("admin_test.T16", x)
+	if err != nil || etag != "0" {
+		t.Fatalf("Expected a nil version to be version 0. Got %q %v", etag, err)
+	}
+	if h.staleEtag("admin_test.T16", etag, etag) {
+		t.Fatal("The etag of a nil version is stale")
+	}
+
+	id := bson.ObjectIdHex("4f07c34779bf562daff8640c")
+	expected := bson.M{"_id": id, "$or": []bson.M{{"ver": 0}, {"ver": nil}, {"ver": bson.M{"$exists": false}}}}
+	if q, err := h.matchQuery("admin_test.T16", id, x); err != nil || !reflect.DeepEqual(q, expected) {
+		t.Fatalf("Expected %v. Got %v %v", expected, q, err)
+	}
+
+	if err := h.bumpVersion("admin_test.T16", x, etag); err != nil || x.Ver == nil || *x.Ver != 1 {
+		t.Fatalf("Expected version 1. Got %v %v", x.Ver, err)
+	}
+	if etag, _ := h.etag("admin_test.T16", x); etag != "1" {
+		t.Fatalf("Expected the version as the etag. Got %q", etag)
+	}
+}
+
+func TestBumpVersion(t *testing.T) {
+	h := &Admin{}
+	h.Register(T10{}, "admin_test.T10", &Options{VersionField: "Level"})
+
+	//the version always comes from the etag, not anything submitted
+	x := &T10{Level: 100}
+	if err := h.bumpVersion("admin_test.T10", x, "4"); err != nil {
+		t.Fatal(err)
+	}
+	if x.Level != 5 {
+		t.Fatalf("Expected version 5. Got %d", x.Level)
+	}
+
+	if err := h.bumpVersion("admin_test.T10", x, ""); err != nil || x.Level != 1 {
+		t.Fatalf("Expected version 1 without an etag. Got %d %v", x.Level, err)
+	}
+}
+
+func TestCheckVersionField(t *testing.T) {
+	h := &Admin{}
+
+	defer func() {
+		if err := recover(); err == nil {
+			t.Fatal("No panic with a version field that isn't an integer")
+		}
+	}()
+	h.Register(T10{}, "admin_test.T10", &Options{VersionField: "Status"})
+}
+
+func TestFormHidden(t *testing.T) {
+	f := Form{
+		object: T10{},
+		hidden: url.Values{etagKey: {`"abc"`}},
+	}
+
+	expected := `<input type="hidden" name="_etag" value="&#34;abc&#34;">`
+	if got := f.ExecuteText(); got != expected {
+		t.Fatalf("Expected %s. Got %s", expected, got)
+	}
+}
+
+func TestStaleEtag(t *testing.T) {
+	h := &Admin{}
+	h.Register(T12{}, "admin_test.T12", nil)
+	h.Register(T10{}, "admin_test.T10", &Options{VersionField: "Level"})
+
+	if h.staleEtag("admin_test.T12", "", "abc") {
+		t.Error("Missing etag is a conflict without a VersionField")
+	}
+	if !h.staleEtag("admin_test.T10", "", "3") {
+		t.Error("Missing etag is not a conflict with a VersionField")
+	}
+	if !h.staleEtag("admin_test.T10", "2", "3") || h.staleEtag("admin_test.T10", "3", "3") {
+		t.Error("Sent etag not compared")
+	}
+}
+
+func containsChange(changes []Change, c Change) bool {
+	for _, change := range changes {
+		if change == c {
+			return true
+		}
+	}
+	return false
+}
+
+func TestUpdateMissingEtag(t *testing.T) {
+	r := &TestRenderer{}
+	h := &Admin{
+		Session:  session,
+		Renderer: r,
+	}
+	h.Register(T12{}, "admin_test.T12", &Options{VersionField: "Rev"})
+
+	id := bson.NewObjectId()
+	c := session.DB("admin_test").C("T12")
+	if err := c.Insert(T12{ID: id, Name: "foo", Rev: 1}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Remove(bson.M{"_id": id}) })
+
+	Post(t, h, "/update/admin_test.T12/"+id.Hex(), url.Values{"Name": {"bar"}})
+	ctx, ok := r.Last().Params.(UpdateContext)
+	if !ok || !ctx.Conflict || ctx.Success {
+		t.Fatalf("Expected a conflict without an etag. Got %v", r.Last())
+	}
+	if !containsChange(ctx.Compare, Change{"Name", "foo", "bar"}) {
+		t.Errorf("Expected the name compared with the submitted one. Got %v", ctx.Compare)
+	}
+
+	var x T12
+	if err := c.Find(bson.M{"_id": id}).One(&x); err != nil {
+		t.Fatal(err)
+	}
+	if x.Name != "foo" || x.Rev != 1 {
+		t.Fatalf("Update without an etag was saved: %+v", x)
+	}
+
+	Post(t, h, "/update/admin_test.T12/"+id.Hex(), url.Values{"Name": {"bar"}, etagKey: {"1"}})
+	if ctx, ok := r.Last().Params.(UpdateContext); !ok || ctx.Conflict || !ctx.Success {
+		t.Fatalf("Expected the update to succeed with the etag. Got %v", r.Last())
+	}
+}
@@ -0,0 +1,291 @@
+package admin
+
+import (
+	"fmt"
+	"launchpad.net/mgo"
+	"launchpad.net/mgo/bson"
+	"net/http"
+	"time"
+)
+
+const (
+	widgetTimeout = 3 * time.Second //longest the index waits for counts and widgets
+	widgetCache   = time.Minute     //how long counts and widgets are reused for
+	recentLimit   = 10              //audit entries shown on the index
+	seriesDays    = 30              //days in a SeriesWidget without Days
+)
+
+//Widget is a panel on the dashboard of the index page. Data computes what the
+//panel shows, and Template names the template that renders it with a
+//WidgetResult, like "widget.count". The default renderer shows a widget whose
+//template it can't find with an error. Widgets are computed concurrently and
+//their data is cached and shared by every user for a minute, so it should not
+//depend on who is looking.
+type Widget interface {
+	Template() string
+	Data(WidgetContext) (interface{}, error)
+}
+
+//WidgetContext is passed to a Widget to compute its data.
+type WidgetContext struct {
+	admin *Admin
+}
+
+//C returns the mgo.Collection for the database.collection.
+func (w WidgetContext) C(coll string) *mgo.Collection {
+	return w.admin.collFor(coll)
+}
+
+//Find returns the query for the documents in the database.collection matching
+//the query, leaving out trashed documents.
+func (w WidgetContext) Find(coll string, q bson.M) *mgo.Query {
+	//copy the query so widgets can reuse theirs
+	live := bson.M{}
+	for key, val := range q {
+		live[key] = val
+	}
+	return w.C(coll).Find(w.admin.liveQuery(coll, live))
+}
+
+//WidgetResult is a Widget computed for the dashboard. Data is what the widget
+//returned, or Error why there is none, which includes taking too long. Updated
+//is when the data was computed, and Locale is the locale of the page for
+//translating the widget.
+type WidgetResult struct {
+	Template string
+	Data     interface{}
+	Error    error
+	Updated  time.Time
+	Locale   Locale
+}
+
+//CountWidget is a Widget showing the number of documents in the
+//database.collection matching the Filter, like orders waiting to be shipped.
+type CountWidget struct {
+	Label      string
+	Collection string
+	Filter     bson.M
+}
+
+//CountData is the data of a CountWidget.
+type CountData struct {
+	Label      string
+	Collection string
+	Count      int
+}
+
+//Template implements the Widget interface.
+func (c CountWidget) Template() string { return "widget.count" }
+
+//Data implements the Widget interface.
+func (c CountWidget) Data(ctx WidgetContext) (interface{}, error) {
+	n, err := ctx.Find(c.Collection, c.Filter).Count()
+	if err != nil {
+		return nil, err
+	}
+	return CountData{Label: c.Label, Collection: c.Collection, Count: n}, nil
+}
+
+//SeriesWidget is a Widget showing the number of documents in the
+//database.collection created on each of the last Days days, going by the time
+//in Field, the key of a time.Time in the documents. Days defaults to 30.
+type SeriesWidget struct {
+	Label      string
+	Collection string
+	Field      string
+	Days       int
+}
+
+//SeriesData is the data of a SeriesWidget. Counts has the number of documents
+//for every day starting at Start, and Max is the largest of them.
+type SeriesData struct {
+	Label      string
+	Collection string
+	Start      time.Time
+	Counts     []int
+	Max        int
+}
+
+//Day returns the day of the count at the index.
+func (s SeriesData) Day(i int) time.Time {
+	return s.Start.AddDate(0, 0, i)
+}
+
+//Percent returns the count as a percentage of the largest count, for drawing
+//bars.
+func (s SeriesData) Percent(count int) int {
+	if s.Max == 0 {
+		return 0
+	}
+	return count * 100 / s.Max
+}
+
+//Template implements the Widget interface.
+func (s SeriesWidget) Template() string { return "widget.series" }
+
+//Data implements the Widget interface.
+func (s SeriesWidget) Data(ctx WidgetContext) (interface{}, error) {
+	return s.data(ctx, time.Now())
+}
+
+//data computes the counts for the days up to and including now.
+func (s SeriesWidget) data(ctx WidgetContext, now time.Time) (interface{}, error) {
+	days := s.Days
+	if days < 1 {
+		days = seriesDays
+	}
+	now = now.UTC()
+	start := time.Date(now.Year(), now.Month(), now.Day()-days+1, 0, 0, 0, 0, time.UTC)
+
+	data := SeriesData{
+		Label:      s.Label,
+		Collection: s.Collection,
+		Start:      start,
+		Counts:     make([]int, days),
+	}
+
+	query := ctx.Find(s.Collection, bson.M{s.Field: bson.M{"$gte": start}})
+	iter := query.Select(bson.M{s.Field: 1}).Iter()
+	for {
+		var doc bson.M
+		if !iter.Next(&doc) {
+			break
+		}
+		data.add(doc[s.Field])
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+//add counts a document created at the time, if it is one and in the series.
+func (s *SeriesData) add(v interface{}) {
+	t, ok := v.(time.Time)
+	if !ok || t.Before(s.Start) {
+		return
+	}
+	if day := int(t.Sub(s.Start) / (24 * time.Hour)); day < len(s.Counts) {
+		s.Counts[day]++
+		if s.Counts[day] > s.Max {
+			s.Max = s.Counts[day]
+		}
+	}
+}
+
+//cached is a value computed for the dashboard. done is closed once the value
+//is computed.
+type cached struct {
+	value interface{}
+	err   error
+	at    time.Time
+	done  chan struct{}
+}
+
+//fresh returns if the value is being computed or was computed recently.
+func (c *cached) fresh(now time.Time) bool {
+	select {
+	case <-c.done:
+		return now.Sub(c.at) < widgetCache
+	default:
+		return true
+	}
+}
+
+//refresh returns the cached value for the key, starting to compute it again if
+//it is stale. The computation carries on in the background if nobody waits for
+//it, so that it is ready for the next request.
+func (a *Admin) refresh(key string, compute func() (interface{}, error)) *cached {
+	a.dash_mu.Lock()
+	defer a.dash_mu.Unlock()
+
+	if c, ok := a.dash_cache[key]; ok && c.fresh(time.Now()) {
+		return c
+	}
+
+	c := &cached{done: make(chan struct{})}
+	if a.dash_cache == nil {
+		a.dash_cache = map[string]*cached{}
+	}
+	a.dash_cache[key] = c
+
+	go func() {
+		defer close(c.done)
+		defer func() {
+			if err := recover(); err != nil {
+				c.err = fmt.Errorf("%v", err)
+			}
+			c.at = time.Now()
+		}()
+		c.value, c.err = compute()
+	}()
+	return c
+}
+
+//wait returns the value, when it was computed and any error computing it, or a
+//timeout error if it isn't computed before the deadline.
+func (c *cached) wait(deadline <-chan struct{}) (interface{}, time.Time, error) {
+	select {
+	case <-c.done:
+		return c.value, c.at, c.err
+	case <-deadline:
+		return nil, time.Time{}, fmt.Errorf("Timed out")
+	}
+}
+
+//dashboard computes the counts of every managed collection and the Widgets of
+//the admin concurrently, waiting at most widgetTimeout for them.
+func (a *Admin) dashboard(req *http.Request) (IndexContext, error) {
+	ctx := IndexContext{
+		BaseContext: a.baseContext(req),
+		Counts:      map[string]int{},
+		Widgets:     make([]WidgetResult, len(a.Widgets)),
+	}
+
+	deadline := make(chan struct{})
+	timer := time.AfterFunc(widgetTimeout, func() { close(deadline) })
+	defer timer.Stop()
+
+	counts := map[string]*cached{}
+	for coll := range a.types {
+		coll := coll
+		counts[coll] = a.refresh("count:"+coll, func() (interface{}, error) {
+			return a.collFor(coll).Find(a.liveQuery(coll, nil)).Count()
+		})
+	}
+
+	widgets := make([]*cached, len(a.Widgets))
+	for i, widget := range a.Widgets {
+		widget := widget
+		widgets[i] = a.refresh(fmt.Sprintf("widget:%d", i), func() (interface{}, error) {
+			return widget.Data(WidgetContext{a})
+		})
+	}
+
+	for coll, c := range counts {
+		if n, _, err := c.wait(deadline); err == nil {
+			ctx.Counts[coll] = n.(int)
+		}
+	}
+	for i, c := range widgets {
+		data, at, err := c.wait(deadline)
+		ctx.Widgets[i] = WidgetResult{
+			Template: a.Widgets[i].Template(),
+			Data:     data,
+			Error:    err,
+			Updated:  at,
+			Locale:   ctx.Locale,
+		}
+	}
+
+	//the latest changes come straight from the audit log
+	if searcher, ok := a.Audit.(AuditSearcher); ok {
+		recent, _, err := searcher.Search(AuditFilter{}, 0, recentLimit)
+		if err != nil {
+			return ctx, err
+		}
+		ctx.Recent = recent
+	}
+
+	return ctx, nil
+}
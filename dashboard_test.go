@@ -0,0 +1,119 @@
+package admin
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//funcWidget is a Widget computed by a function for tests.
+type funcWidget func() (interface{}, error)
+
+func (f funcWidget) Template() string                        { return "widget.test" }
+func (f funcWidget) Data(WidgetContext) (interface{}, error) { return f() }
+
+func TestSeriesData(t *testing.T) {
+	start := time.Date(2012, 3, 1, 0, 0, 0, 0, time.UTC)
+	s := SeriesData{Start: start, Counts: make([]int, 3)}
+
+	for _, v := range []interface{}{
+		start,
+		start.Add(30 * time.Hour),
+		start.Add(47 * time.Hour),
+		start.Add(-time.Hour),
+		start.Add(72 * time.Hour),
+		"not a time",
+	} {
+		s.add(v)
+	}
+
+	if s.Counts[0] != 1 || s.Counts[1] != 2 || s.Counts[2] != 0 || s.Max != 2 {
+		t.Errorf("Unexpected counts %v max %d", s.Counts, s.Max)
+	}
+	if s.Percent(1) != 50 || s.Percent(2) != 100 || (SeriesData{}).Percent(0) != 0 {
+		t.Error("Unexpected percentages")
+	}
+	if !s.Day(2).Equal(start.AddDate(0, 0, 2)) {
+		t.Errorf("Unexpected day %v", s.Day(2))
+	}
+}
+
+func TestRefreshCaches(t *testing.T) {
+	h := &Admin{}
+	var calls int32
+	compute := func() (interface{}, error) {
+		return atomic.AddInt32(&calls, 1), nil
+	}
+
+	never := make(chan struct{})
+	for i := 0; i < 3; i++ {
+		v, _, err := h.refresh("key", compute).wait(never)
+		if err != nil || v.(int32) != 1 {
+			t.Fatalf("Expected the cached value. Got %v %v", v, err)
+		}
+	}
+
+	//stale values are computed again
+	h.dash_cache["key"].at = time.Now().Add(-2 * widgetCache)
+	if v, _, _ := h.refresh("key", compute).wait(never); v.(int32) != 2 {
+		t.Errorf("Expected a new value. Got %v", v)
+	}
+
+	_, _, err := h.refresh("panics", func() (interface{}, error) { panic("boom") }).wait(never)
+	if err == nil || err.Error() != "boom" {
+		t.Errorf("Expected the panic as an error. Got %v", err)
+	}
+}
+
+func TestDashboardWidgets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "admin_dashboard")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	audit := &FileAudit{Path: filepath.Join(dir, "audit.log")}
+	for _, action := range []string{ActionCreate, ActionUpdate} {
+		if err := audit.Record(AuditEntry{User: "bob", Collection: "db.coll", Object: "1", Action: action}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	release := make(chan struct{})
+	defer close(release)
+
+	h := &Admin{Audit: audit, Widgets: []Widget{
+		funcWidget(func() (interface{}, error) { return 5, nil }),
+		funcWidget(func() (interface{}, error) { return nil, errors.New("broken") }),
+		funcWidget(func() (interface{}, error) { <-release; return 1, nil }),
+	}}
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	start := time.Now()
+	ctx, err := h.dashboard(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > widgetTimeout+time.Second {
+		t.Errorf("Waited too long for the slow widget: %s", elapsed)
+	}
+
+	w := ctx.Widgets
+	if len(w) != 3 || w[0].Data != 5 || w[0].Template != "widget.test" || w[0].Updated.IsZero() {
+		t.Fatalf("Unexpected widgets %v", w)
+	}
+	if w[1].Error == nil || w[1].Error.Error() != "broken" {
+		t.Errorf("Expected the widget error. Got %v", w[1].Error)
+	}
+	if w[2].Error == nil || w[2].Data != nil {
+		t.Errorf("Expected the slow widget to time out. Got %v", w[2])
+	}
+	if len(ctx.Recent) != 2 || ctx.Recent[0].Action != ActionUpdate {
+		t.Errorf("Expected the latest audit entries. Got %v", ctx.Recent)
+	}
+}
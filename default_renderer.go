@@ -1,70 +1,97 @@
 package admin
 
 import (
+	"bytes"
+	"embed"
 	"fmt"
 	"html/template"
+	"io/fs"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
 
-//newDefaultRenderer returns a *defaultRenderer ready to be used.
+//builtinTemplates is the complete set of templates the default renderer uses.
+//Every file defines the template named after it, and base.html defines the
+//shared pieces like the header and footer.
+//
+//go:embed templates/*.html
+var builtinTemplates embed.FS
 
-func newDefaultRenderer(l *log.Logger) *defaultRenderer {
+//newDefaultRenderer returns a *defaultRenderer ready to be used. In dev mode
+//it reloads the templates when they change until done is closed.
+func newDefaultRenderer(l *log.Logger, dev bool, done <-chan struct{}) *defaultRenderer {
 	return &defaultRenderer{
-		mtimes:   make(map[string]time.Time),
-		newtemp:  make(chan *template.Template),
-		currtemp: make(chan *template.Template),
-		logger:   l,
+		mtimes: make(map[string]time.Time),
+		logger: l,
+		dev:    dev,
+		done:   done,
 	}
 }
 
 //defaultRenderer conforms to the Renderer interface and uses some magic templates
 //to create a pretty default interface.
 type defaultRenderer struct {
-	initd    sync.Once
-	mtimes   map[string]time.Time
-	newtemp  chan *template.Template
-	currtemp chan *template.Template
-	logger   *log.Logger
+	initd  sync.Once
+	mtimes map[string]time.Time
+	logger *log.Logger
+	dev    bool
+	done   <-chan struct{}
+
+	//overrides maps a page and collection, like "list.db.coll", to the file
+	//in the template directory overriding the page for the collection
+	overrides map[string]string
+
+	//asset returns the url of an asset for the asset template func
+	asset func(name string) string
+
+	mu    sync.RWMutex
+	tmpl  *template.Template
+	colls map[string]*template.Template
+	err   error
 }
 
-//init is called once on a defaultRenderer. Sets up the system for watching
-//the directory of templates.
+//init is called once on a defaultRenderer. Parses the templates, and in dev mode
+//sets up the system for watching the directory of templates.
 func (d *defaultRenderer) init() {
 	d.initd.Do(func() {
 		//seed the parsing
-		tmpl, err := d.parse()
-		if err != nil {
+		if _, err := d.updateMtimes(); err != nil {
 			panic(err)
 		}
+		tmpl, colls, err := d.build()
+		if err != nil && !d.dev {
+			panic(err)
+		}
+		d.tmpl, d.colls, d.err = tmpl, colls, err
 
-		//setup watchers
-		go d.watch()
-		go d.sender(tmpl)
-	})
-}
-
-//sender is a simple function to always send out the most current template (with
-//very low probability that it stays outdated for long.)
-func (d *defaultRenderer) sender(curr *template.Template) {
-	for {
-		select {
-		case curr = <-d.newtemp:
-		case d.currtemp <- curr:
+		if d.dev {
+			go d.watch()
 		}
-	}
+	})
 }
 
-//Lookup returns a template ready to be executed from the template cache, starting
-//the watcher goroutine if it has not been started to recompile things at runtime.
+//Lookup returns a template ready to be executed from the template cache. In dev
+//mode, if the templates failed to parse since they last changed, it returns a
+//template showing the error instead.
 func (d *defaultRenderer) Lookup(name string) *template.Template {
 	d.init()
 
-	t := (<-d.currtemp).Lookup(name)
+	d.mu.RLock()
+	tmpl, err := d.tmpl, d.err
+	d.mu.RUnlock()
+
+	if err != nil {
+		return parseErrorTemplate(err)
+	}
+
+	t := tmpl.Lookup(name)
 	if t == nil {
 		panic(fmt.Errorf("Can't find requested template: %s", name))
 	}
@@ -72,8 +99,43 @@ func (d *defaultRenderer) Lookup(name string) *template.Template {
 	return t
 }
 
-//dir looks at the environment to find out where the templates live.
-//The default value is "./templates"
+//lookupFor returns the template for the page of the collection, using the
+//collection's override if it has one.
+func (d *defaultRenderer) lookupFor(page, coll string) *template.Template {
+	d.init()
+
+	d.mu.RLock()
+	tmpl := d.colls[page+"."+coll]
+	d.mu.RUnlock()
+
+	if tmpl == nil {
+		return d.Lookup(page)
+	}
+
+	d.mu.RLock()
+	err := d.err
+	d.mu.RUnlock()
+	if err != nil {
+		return parseErrorTemplate(err)
+	}
+
+	t := tmpl.Lookup(page)
+	if t == nil {
+		panic(fmt.Errorf("Can't find requested template: %s for %s", page, coll))
+	}
+	return t
+}
+
+//parseErrorTemplate returns a template that presents the error parsing the
+//templates no matter what it is executed with.
+func parseErrorTemplate(err error) *template.Template {
+	return template.Must(template.New("error").Funcs(template.FuncMap{
+		"error": err.Error,
+	}).Parse(`<!DOCTYPE html><html><body><h1>Error parsing templates</h1><pre>{{error}}</pre></body></html>`))
+}
+
+//dir looks at the environment to find out where the templates overriding the
+//built in ones live. The default value is "./templates"
 func (d *defaultRenderer) dir() string {
 	if dir := os.Getenv("ADMIN_TEMPLATE_DIR"); dir != "" {
 		return dir
@@ -90,12 +152,14 @@ func (d *defaultRenderer) updateMtimes() (bool, error) {
 	}
 
 	var changes bool
+	seen := map[string]bool{}
 	for _, file := range files {
 		info, err := os.Stat(file)
 		if err != nil {
 			return false, err
 		}
 
+		seen[file] = true
 		mtime := info.ModTime()
 		if pmtime, ex := d.mtimes[file]; !ex || mtime != pmtime {
 			changes = true
@@ -103,32 +167,63 @@ func (d *defaultRenderer) updateMtimes() (bool, error) {
 		}
 	}
 
+	//removing an override brings back the built in template
+	for file := range d.mtimes {
+		if !seen[file] {
+			changes = true
+			delete(d.mtimes, file)
+		}
+	}
+
 	return changes, nil
 }
 
-//watch watches the template directory every second for modifications and
-//recompiles the templates.
+//watch recompiles the templates whenever the template directory changes until
+//the renderer is done. Changes are noticed with notifications from the operating
+//system where they're available, and by checking every second otherwise.
 func (d *defaultRenderer) watch() {
-	var ticker = time.NewTicker(1e9) //1 sec
-	//do our watching in a forever loop
-	for {
-		<-ticker.C
+	var tick <-chan time.Time
+	changes, err := watchDir(d.dir(), d.done)
+	if err != nil {
+		d.logger.Printf("Checking templates for changes every second: %s", err)
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
 
-		tmpl, err := d.parse()
-		if err != nil {
-			d.logger.Printf("Error parsing templates: %s", err)
-			continue
+	//reload first to catch changes made before watching started
+	for {
+		d.reload()
+		select {
+		case <-d.done:
+			return
+		case <-changes:
+		case <-tick:
 		}
+	}
+}
 
-		if tmpl != nil {
-			d.newtemp <- tmpl
-			d.logger.Print("Templates updated.")
-		}
+//reload parses the templates if they changed, keeping any error to show it in
+//place of the pages until they're fixed.
+func (d *defaultRenderer) reload() {
+	tmpl, colls, err := d.parse()
+	if err == nil && tmpl == nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err != nil {
+		d.logger.Printf("Error parsing templates: %s", err)
+		d.err = err
+		return
 	}
+	d.tmpl, d.colls, d.err = tmpl, colls, nil
+	d.logger.Print("Templates updated.")
 }
 
-//parse checks the modified times and parses the template directory if required.
-func (d *defaultRenderer) parse() (tmpl *template.Template, err error) {
+//parse checks the modified times and parses the templates if required.
+func (d *defaultRenderer) parse() (tmpl *template.Template, colls map[string]*template.Template, err error) {
 	changed, err := d.updateMtimes()
 	if err != nil {
 		return
@@ -136,16 +231,146 @@ func (d *defaultRenderer) parse() (tmpl *template.Template, err error) {
 	if !changed {
 		return
 	}
+	return d.build()
+}
+
+//collectionPages are the pages that can be overridden for a collection.
+var collectionPages = []string{
+	"list", "detail", "update", "create", "delete",
+	"history", "trash", "bulk", "action",
+}
+
+//checkTemplates panics if the templates in the options override pages that
+//can't be overridden for a collection.
+func checkTemplates(typ reflect.Type, opt *Options) {
+	for page, file := range opt.Templates {
+		if !selects(collectionPages, page) || file == "" {
+			panic(fmt.Sprintf("Can't override the %s page of type %s with %q", page, typ, file))
+		}
+	}
+}
 
-	tmpl = template.New("base")
+//templateOverrides returns the files named by the Templates in the options of
+//every collection, keyed by the page and collection they override.
+func (a *Admin) templateOverrides() map[string]string {
+	overrides := map[string]string{}
+	for coll, info := range a.types {
+		for page, file := range info.Options.Templates {
+			overrides[page+"."+coll] = file
+		}
+	}
+	return overrides
+}
+
+//overrideKey returns the page and collection a file named like
+//"list.db.coll.html" overrides, or the empty string if it isn't named like one.
+func overrideKey(name string) string {
+	key := strings.TrimSuffix(name, filepath.Ext(name))
+	parts := strings.Split(key, ".")
+	if len(parts) != 3 || !selects(collectionPages, parts[0]) {
+		return ""
+	}
+	return key
+}
+
+//build parses the built in templates with every file in the template directory
+//layered on top. A file with the same name as a built in one replaces it, so
+//that only the templates being customized need to be copied, and any other
+//files are parsed after the built in ones so they can redefine templates.
+//
+//Files overriding a page for a collection are each parsed into their own copy
+//of those templates, keyed by the page and collection, so they can redefine the
+//blocks of the page, like "list.content" or "title", without affecting any
+//other collection.
+func (d *defaultRenderer) build() (*template.Template, map[string]*template.Template, error) {
+	tmpl := template.New("base")
 	tmpl.Funcs(template.FuncMap{
-		"noescape": func(a ...interface{}) string {
-			return fmt.Sprint(a...)
+		"noescape": func(a ...interface{}) template.HTML {
+			return template.HTML(fmt.Sprint(a...))
+		},
+		"asset": func(name string) string {
+			if d.asset == nil {
+				return ""
+			}
+			return d.asset(name)
+		},
+		"widget": func(w WidgetResult) (template.HTML, error) {
+			var buf bytes.Buffer
+			err := tmpl.ExecuteTemplate(&buf, w.Template, w)
+			return template.HTML(buf.String()), err
 		},
 	})
 
-	tmpl, err = tmpl.ParseGlob(filepath.Join(d.dir(), "*"))
-	return
+	builtin, err := fs.Glob(builtinTemplates, "templates/*.html")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	//sort out the files overriding pages for collections
+	overrides, files := map[string]string{}, map[string]string{}
+	for file := range d.mtimes {
+		if key := overrideKey(filepath.Base(file)); key != "" {
+			files[key] = file
+			continue
+		}
+		overrides[filepath.Base(file)] = file
+	}
+	for key, name := range d.overrides {
+		file := filepath.Join(d.dir(), name)
+		delete(overrides, name)
+		files[key] = file
+	}
+
+	//built in templates first, unless they're overridden
+	for _, file := range builtin {
+		name := filepath.Base(file)
+		data, err := builtinTemplates.ReadFile(file)
+		if over, ok := overrides[name]; ok {
+			delete(overrides, name)
+			data, err = os.ReadFile(over)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, err := tmpl.New(name).Parse(string(data)); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	//then anything else in the directory, in a stable order
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		data, err := os.ReadFile(overrides[name])
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, err := tmpl.New(name).Parse(string(data)); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	//and finally the pages for collections on top of everything else
+	colls := map[string]*template.Template{}
+	for key, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		clone, err := tmpl.Clone()
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, err := clone.New(filepath.Base(file)).Parse(string(data)); err != nil {
+			return nil, nil, err
+		}
+		colls[key] = clone
+	}
+
+	return tmpl, colls, nil
 }
 
 //NotFound presents a basic 404 with no special body.
@@ -176,22 +401,40 @@ func (r *defaultRenderer) Unauthorized(w http.ResponseWriter, req *http.Request)
 //Detail presents the detail view of an object.
 func (r *defaultRenderer) Detail(w http.ResponseWriter, req *http.Request, c DetailContext) {
 	w.Header().Add("Content-Type", "text/html")
-	if err := r.Lookup("detail").Execute(w, c); err != nil {
+	if err := r.lookupFor("detail", c.Collection).Execute(w, c); err != nil {
 		panic(err)
 	}
 }
 
 func (r *defaultRenderer) Delete(w http.ResponseWriter, req *http.Request, c DeleteContext) {
 	w.Header().Add("Content-Type", "text/html")
-	if err := r.Lookup("delete").Execute(w, c); err != nil {
+	if err := r.lookupFor("delete", c.Collection).Execute(w, c); err != nil {
 		panic(err)
 	}
 }
 
-//Index presents an overall view of the database and the managed collections.
+//Index presents the managed collections without the dashboard.
 func (r *defaultRenderer) Index(w http.ResponseWriter, req *http.Request, c BaseContext) {
+	r.Dashboard(w, req, IndexContext{BaseContext: c})
+}
+
+//Dashboard presents an overall view of the database and the managed collections.
+func (r *defaultRenderer) Dashboard(w http.ResponseWriter, req *http.Request, c IndexContext) {
 	w.Header().Add("Content-Type", "text/html")
-	if err := r.Lookup("index").Execute(w, c); err != nil {
+	tmpl := r.Lookup("index")
+
+	//a widget naming a template that doesn't exist shows it as its error
+	//instead of failing the whole page
+	widgets := make([]WidgetResult, len(c.Widgets))
+	for i, widget := range c.Widgets {
+		if widget.Error == nil && tmpl.Lookup(widget.Template) == nil {
+			widget.Error = fmt.Errorf("Can't find the widget template: %s", widget.Template)
+		}
+		widgets[i] = widget
+	}
+	c.Widgets = widgets
+
+	if err := tmpl.Execute(w, c); err != nil {
 		panic(err)
 	}
 }
@@ -200,7 +443,7 @@ func (r *defaultRenderer) Index(w http.ResponseWriter, req *http.Request, c Base
 //the type was loaded with.
 func (r *defaultRenderer) List(w http.ResponseWriter, req *http.Request, c ListContext) {
 	w.Header().Add("Content-Type", "text/html")
-	if err := r.Lookup("list").Execute(w, c); err != nil {
+	if err := r.lookupFor("list", c.Collection).Execute(w, c); err != nil {
 		panic(err)
 	}
 }
@@ -208,7 +451,7 @@ func (r *defaultRenderer) List(w http.ResponseWriter, req *http.Request, c ListC
 //Update presents a success page or the errors when attempting to update an object.
 func (r *defaultRenderer) Update(w http.ResponseWriter, req *http.Request, c UpdateContext) {
 	w.Header().Add("Content-Type", "text/html")
-	if err := r.Lookup("update").Execute(w, c); err != nil {
+	if err := r.lookupFor("update", c.Collection).Execute(w, c); err != nil {
 		panic(err)
 	}
 }
@@ -216,7 +459,7 @@ func (r *defaultRenderer) Update(w http.ResponseWriter, req *http.Request, c Upd
 //Create presents a success page or the errors when attempting to create an object.
 func (r *defaultRenderer) Create(w http.ResponseWriter, req *http.Request, c CreateContext) {
 	w.Header().Add("Content-Type", "text/html")
-	if err := r.Lookup("create").Execute(w, c); err != nil {
+	if err := r.lookupFor("create", c.Collection).Execute(w, c); err != nil {
 		panic(err)
 	}
 }
@@ -229,6 +472,55 @@ func (r *defaultRenderer) Authorize(w http.ResponseWriter, req *http.Request, c
 	}
 }
 
+//Audit presents a page of entries in the audit log.
+func (r *defaultRenderer) Audit(w http.ResponseWriter, req *http.Request, c AuditContext) {
+	w.Header().Add("Content-Type", "text/html")
+	if err := r.Lookup("audit").Execute(w, c); err != nil {
+		panic(err)
+	}
+}
+
+//History presents the saved versions of an object.
+func (r *defaultRenderer) History(w http.ResponseWriter, req *http.Request, c HistoryContext) {
+	w.Header().Add("Content-Type", "text/html")
+	if err := r.lookupFor("history", c.Collection).Execute(w, c); err != nil {
+		panic(err)
+	}
+}
+
+//Trash presents the trashed documents of a collection.
+func (r *defaultRenderer) Trash(w http.ResponseWriter, req *http.Request, c TrashContext) {
+	w.Header().Add("Content-Type", "text/html")
+	if err := r.lookupFor("trash", c.Collection).Execute(w, c); err != nil {
+		panic(err)
+	}
+}
+
+//Bulk presents the confirmation and results of a bulk action.
+func (r *defaultRenderer) Bulk(w http.ResponseWriter, req *http.Request, c BulkContext) {
+	w.Header().Add("Content-Type", "text/html")
+	if err := r.lookupFor("bulk", c.Collection).Execute(w, c); err != nil {
+		panic(err)
+	}
+}
+
+//Action presents the parameters, confirmation and result of an action on an
+//object.
+func (r *defaultRenderer) Action(w http.ResponseWriter, req *http.Request, c ObjectActionContext) {
+	w.Header().Add("Content-Type", "text/html")
+	if err := r.lookupFor("action", c.Collection).Execute(w, c); err != nil {
+		panic(err)
+	}
+}
+
+//Webhooks presents the log of webhook deliveries.
+func (r *defaultRenderer) Webhooks(w http.ResponseWriter, req *http.Request, c WebhookContext) {
+	w.Header().Add("Content-Type", "text/html")
+	if err := r.Lookup("webhooks").Execute(w, c); err != nil {
+		panic(err)
+	}
+}
+
 //LoggedOut presents a page thanking the user for spending time with the site.
 func (r *defaultRenderer) LoggedOut(w http.ResponseWriter, req *http.Request, c BaseContext) {
 	w.Header().Add("Content-Type", "text/html")
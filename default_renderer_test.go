@@ -0,0 +1,230 @@
+package admin
+
+import (
+	"bytes"
+	"html/template"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+//renderTemplates returns a defaultRenderer using the templates in dir for the
+//rest of the test.
+func renderTemplates(t *testing.T, dir string) *defaultRenderer {
+	os.Setenv("ADMIN_TEMPLATE_DIR", dir)
+	t.Cleanup(func() { os.Setenv("ADMIN_TEMPLATE_DIR", "") })
+
+	d := newDefaultRenderer(log.New(ioutil.Discard, "", 0), false, nil)
+	if _, err := d.updateMtimes(); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestBuiltinTemplates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "admin_templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tmpl, _, err := renderTemplates(t, dir).build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//every page the renderer looks up is built in
+	for _, name := range []string{
+		"404", "internal", "unauthorized", "detail", "delete", "index", "list",
+		"update", "create", "login", "logout", "audit", "history", "trash",
+		"bulk", "action", "webhooks",
+	} {
+		if tmpl.Lookup(name) == nil {
+			t.Errorf("Missing built in template %s", name)
+		}
+	}
+}
+
+func TestTemplateOverride(t *testing.T) {
+	dir, err := ioutil.TempDir("", "admin_templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	custom := `{{define "404"}}custom not found{{end}}`
+	if err := ioutil.WriteFile(filepath.Join(dir, "404.html"), []byte(custom), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl, _, err := renderTemplates(t, dir).build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Lookup("404").Execute(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "custom not found" {
+		t.Fatalf("Expected the override. Got %q", buf.String())
+	}
+
+	//the rest are still built in
+	buf.Reset()
+	if err := tmpl.Lookup("internal").Execute(&buf, "oops"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "oops") {
+		t.Fatalf("Expected the built in internal template. Got %q", buf.String())
+	}
+}
+
+//waitFor checks the condition until it is true or a few seconds pass.
+func waitFor(cond func() bool) bool {
+	for i := 0; i < 100; i++ {
+		if cond() {
+			return true
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return false
+}
+
+func TestDevModeReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "admin_templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	os.Setenv("ADMIN_TEMPLATE_DIR", dir)
+	defer os.Setenv("ADMIN_TEMPLATE_DIR", "")
+
+	done := make(chan struct{})
+	defer close(done)
+	d := newDefaultRenderer(log.New(ioutil.Discard, "", 0), true, done)
+
+	render := func() string {
+		var buf bytes.Buffer
+		d.Lookup("404").Execute(&buf, nil)
+		return buf.String()
+	}
+	if !strings.Contains(render(), "Not found") {
+		t.Fatalf("Expected the built in template. Got %q", render())
+	}
+
+	//write files atomically so a half written one is never parsed
+	write := func(data string) {
+		tmp := filepath.Join(dir, ".tmp")
+		if err := ioutil.WriteFile(tmp, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmp, filepath.Join(dir, "404.html")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//parse errors are shown in place of the page
+	write(`{{define "404"}}broken{{end`)
+	if !waitFor(func() bool { return strings.Contains(render(), "Error parsing templates") }) {
+		t.Fatalf("Expected the parse error. Got %q", render())
+	}
+
+	write(`{{define "404"}}fixed{{end}}`)
+	if !waitFor(func() bool { return render() == "fixed" }) {
+		t.Fatalf("Expected the fixed template. Got %q", render())
+	}
+}
+
+func TestCollectionTemplates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "admin_templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"list.db.orders.html": `{{define "title"}}Orders{{end}}{{define "list.content"}}orders{{end}}`,
+		"fancy.html":          `{{define "detail.content"}}fancy{{end}}`,
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d := renderTemplates(t, dir)
+	d.overrides = map[string]string{"detail.db.people": "fancy.html"}
+	tmpl, colls, err := d.build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	execute := func(tmpl *template.Template, name string) string {
+		var buf bytes.Buffer
+		if err := tmpl.Lookup(name).Execute(&buf, BaseContext{}); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+
+	//overrides only apply to their collection
+	if got := execute(colls["list.db.orders"], "title"); got != "Orders" {
+		t.Errorf("Expected the overridden title. Got %q", got)
+	}
+	if got := execute(colls["list.db.orders"], "list.content"); got != "orders" {
+		t.Errorf("Expected the overridden list. Got %q", got)
+	}
+	if got := execute(colls["detail.db.people"], "detail.content"); got != "fancy" {
+		t.Errorf("Expected the overridden detail. Got %q", got)
+	}
+	if got := execute(tmpl, "title"); got != "Admin" {
+		t.Errorf("Expected the built in title. Got %q", got)
+	}
+	if tmpl.Lookup("list.content") == nil || colls["list.db.orders"].Lookup("list") == nil {
+		t.Error("Expected the built in list page")
+	}
+}
+
+func TestCheckTemplates(t *testing.T) {
+	h := &Admin{}
+
+	defer func() {
+		if err := recover(); err == nil {
+			t.Fatal("No panic overriding a page without a collection")
+		}
+	}()
+	h.Register(T12{}, "admin_test.T12", &Options{Templates: map[string]string{"index": "index.html"}})
+}
+
+func TestMissingWidgetTemplate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "admin_templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	custom := `{{define "index"}}{{range .Widgets}}[{{if .Error}}{{.Error}}{{else}}{{widget .}}{{end}}]{{end}}{{end}}` +
+		`{{define "widget.test"}}data {{.Data}}{{end}}`
+	if err := ioutil.WriteFile(filepath.Join(dir, "index.html"), []byte(custom), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	renderTemplates(t, dir).Dashboard(w, &http.Request{}, IndexContext{Widgets: []WidgetResult{
+		{Template: "widget.test", Data: 1},
+		{Template: "widget.missing", Data: 2},
+	}})
+
+	expected := "[data 1][Can&#39;t find the widget template: widget.missing]"
+	if got := w.Body.String(); got != expected {
+		t.Fatalf("Expected %q. Got %q", expected, got)
+	}
+}
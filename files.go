@@ -0,0 +1,540 @@
+package admin
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/zeebo/admin/forms"
+	"io"
+	"launchpad.net/mgo"
+	"launchpad.net/mgo/bson"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+//File is a field type for uploaded files. The contents of the file are stored
+//in the Admin's BlobStore and the File saved in the document references them.
+//Files are loaded from multipart forms submitted to the create and update
+//handlers, and are limited by the UploadLimits in the collection's Options.
+type File struct {
+	ID          string `bson:"id"`
+	Name        string `bson:"name"`
+	ContentType string `bson:"content_type"`
+	Size        int64  `bson:"size"`
+}
+
+//IsImage returns if the file has an image content type and can be previewed.
+func (f File) IsImage() bool {
+	return strings.HasPrefix(f.ContentType, "image/")
+}
+
+//fileType is the reflect.Type of a File for finding File fields.
+var fileType = reflect.TypeOf(File{})
+
+//fileCodec is the built in Codec for File values. Files are displayed by name
+//and can only be loaded from multipart uploads, never from form values.
+type fileCodec struct{}
+
+func (fileCodec) Format(v interface{}) string { return v.(File).Name }
+
+func (fileCodec) Parse(data string) (interface{}, error) {
+	return nil, fmt.Errorf("Files must be uploaded with a multipart form")
+}
+
+func (fileCodec) Widget() forms.Field { return forms.File }
+
+func init() {
+	RegisterCodec(File{}, fileCodec{})
+}
+
+//UploadLimits restricts the files that can be uploaded into a File field. The
+//body of a create or update request is capped at the MaxSize of every File
+//field in the collection together, plus 32MB for the other values, unless one
+//of them has no limit.
+type UploadLimits struct {
+	//MaxSize is the largest file in bytes that can be uploaded. 0 means no limit.
+	MaxSize int64
+
+	//Types is the list of allowed MIME types, detected from the contents of the
+	//file. Entries like "image/*" match any subtype. nil allows any type.
+	Types []string
+}
+
+//DefaultUploadLimits are the limits used for File fields that do not have an
+//entry in the Uploads field of the collection's Options.
+var DefaultUploadLimits = UploadLimits{
+	MaxSize: 10 << 20, //10MB
+}
+
+//allows returns if the content type is allowed by the limits.
+func (u UploadLimits) allows(ctype string) bool {
+	if u.Types == nil {
+		return true
+	}
+	for _, typ := range u.Types {
+		if typ == ctype {
+			return true
+		}
+		if strings.HasSuffix(typ, "/*") && strings.HasPrefix(ctype, typ[:len(typ)-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+//ErrBlobNotFound is returned by a BlobStore when asked for an id it does not
+//have.
+var ErrBlobNotFound = errors.New("Blob not found")
+
+//BlobStore is a type that stores the contents of uploaded files. Put stores the
+//data and returns an id to reference it with. Get returns the data for an id
+//along with its content type, and ErrBlobNotFound if the id is unknown.
+type BlobStore interface {
+	Put(name, contentType string, data io.Reader) (id string, err error)
+	Get(id string) (data io.ReadCloser, contentType string, err error)
+	Delete(id string) error
+}
+
+//sniffContentType returns the content type of the data without parameters,
+//detected from its first bytes, and seeks it back to the start.
+func sniffContentType(data io.ReadSeeker) (string, error) {
+	var sniff [512]byte
+	n, err := io.ReadFull(data, sniff[:])
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	if _, err := data.Seek(0, 0); err != nil {
+		return "", err
+	}
+
+	ctype := http.DetectContentType(sniff[:n])
+	if i := strings.Index(ctype, ";"); i >= 0 {
+		ctype = ctype[:i]
+	}
+	return ctype, nil
+}
+
+//LocalStore is a BlobStore that stores files in a directory on disk.
+type LocalStore struct {
+	Dir string
+}
+
+//localPath returns the path to the file for the id, ensuring the id can't
+//escape the directory.
+func (l LocalStore) localPath(id string) (string, error) {
+	if id == "" || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return "", ErrBlobNotFound
+	}
+	return filepath.Join(l.Dir, id), nil
+}
+
+//Put implements the BlobStore interface. Ids are random with the extension of
+//the file name.
+func (l LocalStore) Put(name, contentType string, data io.Reader) (string, error) {
+	var buf [16]byte
+	if _, err := io.ReadFull(rand.Reader, buf[:]); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(buf[:]) + strings.ToLower(filepath.Ext(name))
+
+	file, err := os.OpenFile(filepath.Join(l.Dir, id), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, data); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return id, nil
+}
+
+//Get implements the BlobStore interface. The content type is sniffed from the
+//data like it is on upload, since the extension in the id comes from the client.
+func (l LocalStore) Get(id string) (io.ReadCloser, string, error) {
+	p, err := l.localPath(id)
+	if err != nil {
+		return nil, "", err
+	}
+
+	file, err := os.Open(p)
+	if os.IsNotExist(err) {
+		return nil, "", ErrBlobNotFound
+	} else if err != nil {
+		return nil, "", err
+	}
+
+	ctype, err := sniffContentType(file)
+	if err != nil {
+		file.Close()
+		return nil, "", err
+	}
+	return file, ctype, nil
+}
+
+//Delete implements the BlobStore interface.
+func (l LocalStore) Delete(id string) error {
+	p, err := l.localPath(id)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(p)
+	if os.IsNotExist(err) {
+		return ErrBlobNotFound
+	}
+	return err
+}
+
+//GridFSStore is a BlobStore that stores files in mongo's GridFS. Prefix is the
+//GridFS prefix, and defaults to "fs".
+type GridFSStore struct {
+	Session *mgo.Session
+	DB      string
+	Prefix  string
+}
+
+//gridfs returns the mgo.GridFS for the store.
+func (g GridFSStore) gridfs() *mgo.GridFS {
+	prefix := g.Prefix
+	if prefix == "" {
+		prefix = "fs"
+	}
+	return g.Session.DB(g.DB).GridFS(prefix)
+}
+
+//Put implements the BlobStore interface. Ids are the hex of the GridFS file id.
+func (g GridFSStore) Put(name, contentType string, data io.Reader) (string, error) {
+	file, err := g.gridfs().Create(name)
+	if err != nil {
+		return "", err
+	}
+	file.SetContentType(contentType)
+
+	if _, err := io.Copy(file, data); err != nil {
+		file.Close()
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		return "", err
+	}
+
+	return file.Id().(bson.ObjectId).Hex(), nil
+}
+
+//Get implements the BlobStore interface.
+func (g GridFSStore) Get(id string) (io.ReadCloser, string, error) {
+	oid, err := objectIdCodec{}.Parse(id)
+	if err != nil || id == "" {
+		return nil, "", ErrBlobNotFound
+	}
+
+	file, err := g.gridfs().OpenId(oid)
+	if err != nil {
+		if err.Error() == "Document not found" {
+			return nil, "", ErrBlobNotFound
+		}
+		return nil, "", err
+	}
+	return file, file.ContentType(), nil
+}
+
+//Delete implements the BlobStore interface.
+func (g GridFSStore) Delete(id string) error {
+	oid, err := objectIdCodec{}.Parse(id)
+	if err != nil || id == "" {
+		return ErrBlobNotFound
+	}
+	return g.gridfs().RemoveId(oid)
+}
+
+//walkFiles calls fn with the dot separated path and value of every File field
+//in the struct value, recursing into nested structs. Nil pointers to structs
+//are skipped, but nil pointers to Files are passed to fn so they can be
+//allocated.
+func walkFiles(val reflect.Value, prefix string, fn func(string, reflect.Value) error) error {
+	typ := val.Type()
+	for i := 0; i < val.NumField(); i++ {
+		field, name := val.Field(i), prefix+typ.Field(i).Name
+		if typ.Field(i).PkgPath != "" {
+			continue
+		}
+
+		ftyp := indirectType(field.Type())
+		if ftyp == fileType {
+			if err := fn(name, field); err != nil {
+				return err
+			}
+			continue
+		}
+		if isBasic(ftyp) {
+			continue
+		}
+
+		inner, err := indirect(field)
+		if err != nil {
+			continue
+		}
+		if err := walkFiles(inner, name+".", fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//filesIn returns a map of the dot separated path to every uploaded File in the
+//object.
+func filesIn(obj interface{}) map[string]File {
+	val, err := indirect(reflect.ValueOf(obj))
+	if err != nil || val.Kind() != reflect.Struct {
+		return nil
+	}
+
+	files := map[string]File{}
+	walkFiles(val, "", func(name string, field reflect.Value) error {
+		if f, err := indirect(field); err == nil && f.Interface().(File).ID != "" {
+			files[name] = f.Interface().(File)
+		}
+		return nil
+	})
+	return files
+}
+
+//loadFiles stores the files uploaded in the request for every File field in the
+//object, returning LoadingErrors for files that exceed their limits. The files
+//are uploaded under the names of the fields with the prefix.
+func (a *Admin) loadFiles(req *http.Request, coll, prefix string, t Formable) (LoadingErrors, error) {
+	if req.MultipartForm == nil || len(req.MultipartForm.File) == 0 {
+		return nil, nil
+	}
+
+	val, err := indirect(reflect.ValueOf(t))
+	if err != nil {
+		return nil, err
+	}
+
+	errs, limits := LoadingErrors{}, a.types[coll].Options.Uploads
+	err = walkFiles(val, "", func(name string, field reflect.Value) error {
+		headers := req.MultipartForm.File[prefix+name]
+		if len(headers) == 0 || headers[0].Filename == "" {
+			return nil
+		}
+		if a.Files == nil {
+			return fmt.Errorf("File uploaded to %s but no BlobStore is configured", name)
+		}
+
+		limit, ok := limits[name]
+		if !ok {
+			limit = DefaultUploadLimits
+		}
+
+		file, lerr, err := a.storeFile(headers[0], limit)
+		if err != nil {
+			return err
+		}
+		if lerr != nil {
+			errs[name] = lerr
+			return nil
+		}
+
+		alloc(field).Set(reflect.ValueOf(file))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return errs, nil
+}
+
+//storeFile checks the uploaded file against the limits and stores it in the
+//BlobStore. The first error is a loading error from violating the limits, and
+//the second is any error storing the file.
+func (a *Admin) storeFile(header *multipart.FileHeader, limit UploadLimits) (File, error, error) {
+	if limit.MaxSize > 0 && header.Size > limit.MaxSize {
+		return File{}, fmt.Errorf("File is too large. Maximum size is %d bytes", limit.MaxSize), nil
+	}
+
+	data, err := header.Open()
+	if err != nil {
+		return File{}, nil, err
+	}
+	defer data.Close()
+
+	//sniff the content type rather than trusting the client
+	ctype, err := sniffContentType(data)
+	if err != nil {
+		return File{}, nil, err
+	}
+	if !limit.allows(ctype) {
+		return File{}, fmt.Errorf("Files of type %s are not allowed", ctype), nil
+	}
+
+	id, err := a.Files.Put(header.Filename, ctype, data)
+	if err != nil {
+		return File{}, nil, err
+	}
+
+	return File{
+		ID:          id,
+		Name:        header.Filename,
+		ContentType: ctype,
+		Size:        header.Size,
+	}, nil, nil
+}
+
+//uploadSize returns the total MaxSize of the File fields in the collection,
+//and false if any of them is unlimited.
+func (a *Admin) uploadSize(coll string) (size int64, ok bool) {
+	info := a.types[coll]
+	ok = true
+	walkFields(info.Type, "", func(name string, field reflect.StructField) {
+		if indirectType(field.Type) != fileType {
+			return
+		}
+		limit, found := info.Options.Uploads[name]
+		if !found {
+			limit = DefaultUploadLimits
+		}
+		if limit.MaxSize <= 0 {
+			ok = false
+		}
+		size += limit.MaxSize
+	})
+	return
+}
+
+//requestSize returns the largest body a create or update request for the object
+//with the formatted id in the collection may have, which is empty for creates:
+//maxMemory for the values plus the MaxSize of every File field in its form and
+//in the forms of its inline children. It returns 0 if a File field is
+//unlimited.
+func (a *Admin) requestSize(coll, parent string) (int64, error) {
+	size, ok := a.uploadSize(coll)
+	for _, inline := range a.types[coll].Options.Inlines {
+		child, cok := a.uploadSize(inline.Collection)
+		if child == 0 && cok {
+			continue
+		}
+		ok = ok && cok
+
+		//the forms of new children from a failed submission come back along
+		//with the extra ones
+		forms := 2 * inline.Extra
+		if parent != "" {
+			q, err := a.fieldQuery(inline.Collection, inline.Field, parent)
+			if err != nil {
+				return 0, err
+			}
+			key := bsonPath(a.types[inline.Collection].Type, inline.Field)
+			query := a.liveQuery(inline.Collection, bson.M{key: q})
+			n, err := a.collFor(inline.Collection).Find(query).Count()
+			if err != nil {
+				return 0, err
+			}
+			forms += n
+		}
+		size += child * int64(forms)
+	}
+	if !ok {
+		return 0, nil
+	}
+	return maxMemory + size, nil
+}
+
+//limitBody caps the body of the request at the requestSize of the object, so
+//that oversized uploads are refused while they are read instead of after they
+//have been parsed.
+func (a *Admin) limitBody(w http.ResponseWriter, req *http.Request, coll, parent string) error {
+	size, err := a.requestSize(coll, parent)
+	if err != nil {
+		return err
+	}
+	if size > 0 {
+		req.Body = http.MaxBytesReader(w, req.Body, size)
+	}
+	return nil
+}
+
+//bodyError explains the error parsing a body cut off by limitBody.
+func bodyError(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return fmt.Errorf("Request is larger than the %d bytes allowed", tooLarge.Limit)
+	}
+	return err
+}
+
+//cleanupFiles deletes the blobs nothing refers to after saving an object whose
+//files were before and are now after: the files replaced by new uploads if it
+//was saved, or the new uploads if it wasn't.
+func (a *Admin) cleanupFiles(before, after map[string]File, saved bool) {
+	if !saved {
+		before, after = after, before
+	}
+
+	kept := map[string]bool{}
+	for _, f := range after {
+		kept[f.ID] = true
+	}
+	for name, f := range before {
+		if kept[f.ID] || a.Files == nil {
+			continue
+		}
+		if err := a.Files.Delete(f.ID); err != nil && err != ErrBlobNotFound {
+			a.logger.Printf("Error deleting file %s for %s: %s", f.ID, name, err)
+		}
+	}
+}
+
+//deleteFiles removes the blobs for every File in the object, logging any
+//errors.
+func (a *Admin) deleteFiles(obj interface{}) {
+	if a.Files == nil {
+		return
+	}
+	for name, f := range filesIn(obj) {
+		if err := a.Files.Delete(f.ID); err != nil && err != ErrBlobNotFound {
+			a.logger.Printf("Error deleting file %s for %s: %s", f.ID, name, err)
+		}
+	}
+}
+
+//Serves the contents of an uploaded file from the BlobStore
+func (a *Admin) files(w http.ResponseWriter, req *http.Request) {
+	id, n := parseRequest(req.URL.Path)
+
+	//ensure we have an id and nothing else
+	if id == "" || n != "" || a.Files == nil {
+		a.Renderer.NotFound(w, req)
+		return
+	}
+
+	data, ctype, err := a.Files.Get(id)
+	if err == ErrBlobNotFound {
+		a.Renderer.NotFound(w, req)
+		return
+	} else if err != nil {
+		a.Renderer.InternalError(w, req, err)
+		return
+	}
+	defer data.Close()
+
+	//only let the browser display images inline. Everything else is a download
+	//so uploaded html can't run in the admin.
+	w.Header().Set("Content-Type", ctype)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	if !strings.HasPrefix(ctype, "image/") || ctype == "image/svg+xml" {
+		w.Header().Set("Content-Disposition", "attachment")
+	}
+
+	if _, err := io.Copy(w, data); err != nil {
+		a.logger.Printf("Error sending file %s: %s", id, err)
+	}
+}
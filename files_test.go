@@ -0,0 +1,235 @@
+package admin
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+//png is the header of a png file, enough for content sniffing.
+var png = []byte("\x89PNG\x0D\x0A\x1A\x0A\x00\x00\x00\x0DIHDR")
+
+func tempStore(t *testing.T) LocalStore {
+	dir, err := ioutil.TempDir("", "admin_files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return LocalStore{dir}
+}
+
+func multipartRequest(t *testing.T, values map[string]string, files map[string][]byte) *http.Request {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for key, val := range values {
+		mw.WriteField(key, val)
+	}
+	for key, data := range files {
+		fw, err := mw.CreateFormFile(key, key+".png")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write(data)
+	}
+	mw.Close()
+
+	req, err := http.NewRequest("POST", "/create/admin_test.T9", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestLocalStore(t *testing.T) {
+	store := tempStore(t)
+	defer os.RemoveAll(store.Dir)
+
+	id, err := store.Put("pic.PNG", "image/png", bytes.NewReader(png))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, ctype, err := store.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := ioutil.ReadAll(data)
+	data.Close()
+	if !bytes.Equal(got, png) || ctype != "image/png" {
+		t.Fatalf("Got %q %q back from the store", got, ctype)
+	}
+
+	if err := store.Delete(id); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := store.Get(id); err != ErrBlobNotFound {
+		t.Fatalf("Expected ErrBlobNotFound after delete. Got %v", err)
+	}
+
+	//the extension the client named the file with isn't trusted
+	id, err = store.Put("page.png", "text/html", strings.NewReader("<html><script></script></html>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, ctype, err = store.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data.Close()
+	if ctype != "text/html" {
+		t.Fatalf("Expected the sniffed content type. Got %q", ctype)
+	}
+
+	for _, bad := range []string{"", "..", "../passwd", "a/b"} {
+		if _, _, err := store.Get(bad); err != ErrBlobNotFound {
+			t.Errorf("Expected ErrBlobNotFound for %q. Got %v", bad, err)
+		}
+	}
+}
+
+func TestUploadLimitsAllows(t *testing.T) {
+	table := []struct {
+		types    []string
+		ctype    string
+		expected bool
+	}{
+		{nil, "text/html", true},
+		{[]string{"image/*"}, "image/png", true},
+		{[]string{"image/*"}, "text/plain", false},
+		{[]string{"application/pdf"}, "application/pdf", true},
+		{[]string{}, "image/png", false},
+	}
+
+	for _, c := range table {
+		if got := (UploadLimits{Types: c.types}).allows(c.ctype); got != c.expected {
+			t.Errorf("%v allows %q: Expected %v. Got %v", c.types, c.ctype, c.expected, got)
+		}
+	}
+}
+
+func TestLoadFiles(t *testing.T) {
+	store := tempStore(t)
+	defer os.RemoveAll(store.Dir)
+
+	h := &Admin{
+		Files:  store,
+		logger: log.New(ioutil.Discard, "", 0),
+	}
+	h.Register(T9{}, "admin_test.T9", &Options{
+		Uploads: map[string]UploadLimits{
+			"Nested.Doc": {MaxSize: 4},
+		},
+	})
+
+	var x T9
+	req := multipartRequest(t, map[string]string{"Name": "foo"}, map[string][]byte{"Avatar": png})
+	errs, err := h.performLoading(req, "admin_test.T9", &x)
+	if err != nil || len(errs) > 0 {
+		t.Fatalf("Error loading: %v %v", err, errs)
+	}
+
+	if x.Name != "foo" || x.Avatar.ID == "" || !x.Avatar.IsImage() || x.Avatar.Name != "Avatar.png" {
+		t.Fatalf("File not loaded correctly: %+v", x)
+	}
+	if files := filesIn(x); len(files) != 1 || files["Avatar"] != x.Avatar {
+		t.Fatalf("Expected only Avatar in files. Got %v", files)
+	}
+
+	x = T9{}
+	req = multipartRequest(t, nil, map[string][]byte{"Nested.Doc": png})
+	errs, err = h.performLoading(req, "admin_test.T9", &x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !compareErrs(LoadingErrors(errs), []string{"Nested.Doc"}) {
+		t.Fatalf("Expected an error for an oversized file. Got %v", errs)
+	}
+}
+
+func TestRequestSize(t *testing.T) {
+	h := &Admin{}
+	h.Register(T9{}, "admin_test.T9", &Options{
+		Uploads: map[string]UploadLimits{
+			"Nested.Doc": {MaxSize: 4},
+		},
+	})
+	if size, err := h.requestSize("admin_test.T9", ""); err != nil || size != maxMemory+DefaultUploadLimits.MaxSize+4 {
+		t.Fatalf("Unexpected request size %d: %v", size, err)
+	}
+
+	h = &Admin{}
+	h.Register(T9{}, "admin_test.T9", &Options{
+		Uploads: map[string]UploadLimits{
+			"Avatar": {Types: []string{"image/*"}},
+		},
+	})
+	if size, err := h.requestSize("admin_test.T9", ""); err != nil || size != 0 {
+		t.Fatalf("Expected no limit with an unlimited field. Got %d: %v", size, err)
+	}
+}
+
+func TestBodyTooLarge(t *testing.T) {
+	h := &Admin{}
+	h.Register(T9{}, "admin_test.T9", nil)
+
+	req := multipartRequest(t, map[string]string{"Name": "foo"}, map[string][]byte{"Avatar": png})
+	req.Body = http.MaxBytesReader(nil, req.Body, 10)
+
+	var x T9
+	_, err := h.performLoading(req, "admin_test.T9", &x)
+	if err == nil || err.Error() != "Request is larger than the 10 bytes allowed" {
+		t.Fatalf("Expected the request to be too large. Got %v", err)
+	}
+}
+
+func TestCleanupFiles(t *testing.T) {
+	store := tempStore(t)
+	defer os.RemoveAll(store.Dir)
+
+	h := &Admin{
+		Files:  store,
+		logger: log.New(ioutil.Discard, "", 0),
+	}
+
+	put := func() File {
+		id, err := store.Put("pic.png", "image/png", bytes.NewReader(png))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return File{ID: id}
+	}
+	exists := func(f File) bool {
+		data, _, err := store.Get(f.ID)
+		if err == nil {
+			data.Close()
+		}
+		return err == nil
+	}
+
+	//a saved replacement deletes the old file
+	old, kept, replacement := put(), put(), put()
+	h.cleanupFiles(
+		map[string]File{"Avatar": old, "Nested.Doc": kept},
+		map[string]File{"Avatar": replacement, "Nested.Doc": kept},
+		true,
+	)
+	if exists(old) || !exists(kept) || !exists(replacement) {
+		t.Fatal("Expected only the replaced file to be deleted")
+	}
+
+	//an unsaved upload is deleted instead
+	upload := put()
+	h.cleanupFiles(
+		map[string]File{"Avatar": replacement},
+		map[string]File{"Avatar": upload},
+		false,
+	)
+	if !exists(replacement) || exists(upload) {
+		t.Fatal("Expected only the new upload to be deleted")
+	}
+}
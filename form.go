@@ -2,6 +2,7 @@ package admin
 
 import (
 	"fmt"
+	"github.com/zeebo/admin/forms"
 	"net/url"
 	"reflect"
 	"strconv"
@@ -126,13 +127,8 @@ func CreateValues(obj interface{}) (map[string]interface{}, error) {
 		}
 
 		//handle the basic types
-		if field.Kind() != reflect.Struct {
-			switch f := field.Interface().(type) {
-			case hexable:
-				res[name] = f.Hex()
-			default:
-				res[name] = fmt.Sprint(f)
-			}
+		if isBasic(field.Type()) {
+			res[name] = formatValue(field)
 			continue
 		}
 
@@ -178,7 +174,7 @@ func createEmptyValuesType(typ reflect.Type) (m map[string]interface{}, e error)
 			return nil, fmt.Errorf("Unsupported type: %s", field.Kind())
 		}
 
-		if field.Kind() != reflect.Struct {
+		if isBasic(field) {
 			res[name] = ""
 			continue
 		}
@@ -196,8 +192,14 @@ func createEmptyValuesType(typ reflect.Type) (m map[string]interface{}, e error)
 }
 
 //validType checks to see if the reflect.Type's Kind is a supported type. These types
-//are the basic go types (int/string/etc.). More may be supported in the future.
+//are the basic go types (int/string/etc.) and any type with a Codec, which
+//includes types implementing FieldCodec or the encoding.TextMarshaler and
+//encoding.TextUnmarshaler pair.
 func validType(typ reflect.Type) bool {
+	if _, ok := codecFor(typ); ok {
+		return true
+	}
+
 	switch typ.Kind() {
 	case reflect.Slice, reflect.Array, reflect.Chan, reflect.Map, reflect.Uintptr,
 		reflect.Complex128, reflect.Complex64, reflect.Func, reflect.UnsafePointer,
@@ -246,6 +248,11 @@ func loadInto(val reflect.Value, data string) (e error) {
 		}
 	}()
 
+	//types with a codec know how to parse themselves
+	if c, ok := codecFor(val.Type()); ok {
+		return parseCodec(c, val, data)
+	}
+
 	switch val.Kind() {
 	case reflect.Bool:
 		v, err := strconv.ParseBool(data)
@@ -339,6 +346,15 @@ func unflatten(val url.Values, prefix string) d {
 //	bool
 //	string
 //
+//Types with a registered Codec, such as time.Time, time.Duration and
+//bson.ObjectId, are also handled by parsing with the Codec. See RegisterCodec.
+//Types implementing FieldCodec, or both encoding.TextMarshaler and
+//encoding.TextUnmarshaler, are handled by their own methods.
+//
+//Fields with fixed choices, given by the object implementing forms.Chooser or
+//by a choices tag, must be loaded with one of the choices or a LoadingErrors
+//entry is returned for them. See choicesFor for the tag format.
+//
 //If the type is a pointer to any of the handled types, values are allocated
 //up until a basic type is reached. If the passed in object is a Loader loading
 //is passed off to its Load method.
@@ -352,14 +368,15 @@ func Load(form url.Values, obj interface{}) (LoadingErrors, error) {
 		return nil, fmt.Errorf("Can't set to the object sent in: CanSet(%v) IsValid(%v)", val.CanSet(), val.IsValid())
 	}
 
-	return apply(val, unflatten(form, ""), "")
+	return apply(val, unflatten(form, ""), "", chooserFor(obj))
 }
 
 //apply does the heavy lifting for Load, recursing down the type when needed
 //and mangling the LoadingErrors returned to have the correct prefix. obj.Kind()
-//should always be reflect.Struct. Note prefix is only used to generate better
-//error messages in the case of problems.
-func apply(obj reflect.Value, data d, prefix string) (LoadingErrors, error) {
+//should always be reflect.Struct. Note prefix is used to generate better error
+//messages in the case of problems, and to look up the choices for a field from
+//the Chooser, which may be nil.
+func apply(obj reflect.Value, data d, prefix string, ch forms.Chooser) (LoadingErrors, error) {
 	//make sure we have a good value
 	if obj.Kind() != reflect.Struct || !obj.CanSet() || !obj.IsValid() {
 		return nil, fmt.Errorf("Attempted to apply on something that wasn't a struct or was invalid - CanSet(%v) IsValid(%v) Kind(%s)", obj.CanSet(), obj.IsValid(), obj.Kind())
@@ -380,12 +397,18 @@ func apply(obj reflect.Value, data d, prefix string) (LoadingErrors, error) {
 		}
 
 		//handle basic field types
-		if field.Kind() != reflect.Struct {
+		if isBasic(field.Type()) {
 			sval, ok := data[name].(string)
 			if !ok {
 				return nil, fmt.Errorf("Attmped to load a dictionary into a basic type: %s%s", prefix, name)
 			}
 
+			//make sure the value is one of the choices if the field has them
+			if items := choicesFor(ch, typ.Field(i), prefix+name); items != nil && !isChoice(items, sval) {
+				errs[name] = fmt.Errorf("%q is not a valid choice", sval)
+				continue
+			}
+
 			//load the thing into the field and grab the errors
 			if err := loadInto(field, sval); err != nil {
 				errs[name] = err
@@ -401,7 +424,7 @@ func apply(obj reflect.Value, data d, prefix string) (LoadingErrors, error) {
 		}
 
 		//recurse
-		nest_err, ferr := apply(field, dval, fmt.Sprintf("%s%s.", prefix, name))
+		nest_err, ferr := apply(field, dval, fmt.Sprintf("%s%s.", prefix, name), ch)
 		if ferr != nil {
 			return nil, ferr
 		}
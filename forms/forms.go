@@ -26,6 +26,9 @@ type FieldContext struct {
 
 	//used for things like Radio/Select
 	Choices []Item
+
+	//used for Autocomplete as the url of the JSON lookup
+	Source string
 }
 
 //Only supports single valued fields at the moment
@@ -39,6 +42,10 @@ const (
 	Checkbox Field = "Checkbox"
 	Radio    Field = "Radio"
 	Select   Field = "Select"
+	DateTime Field = "DateTime"
+	File     Field = "File"
+
+	Autocomplete Field = "Autocomplete"
 )
 
 func (f Field) String() string {
@@ -1,3 +1,37 @@
 package forms
 
-import "testing"
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDefaultGenerator(t *testing.T) {
+	table := []struct {
+		field    Field
+		ctx      FieldContext
+		contains []string
+	}{
+		{Text, FieldContext{Name: "A", Label: "A", Value: `"x"`}, []string{`type="text"`, `name="A"`, `value="&#34;x&#34;"`}},
+		{Checkbox, FieldContext{Name: "B", Label: "B", Value: "true"}, []string{`type="checkbox"`, ` checked`, `type="hidden"`}},
+		{Select, FieldContext{Name: "C", Label: "C", Value: "2", Choices: []Item{{"One", "1"}, {"Two", "2"}}}, []string{`<option value="2" selected>Two</option>`}},
+		{DateTime, FieldContext{Name: "D", Label: "D", Value: "2012-01-02T15:04:05Z"}, []string{`value="2012-01-02T15:04:05"`}},
+		{Text, FieldContext{Name: "E", Label: "E", Error: errors.New("bad")}, []string{`class="field error"`, `<span class="error">bad</span>`}},
+	}
+
+	for _, c := range table {
+		out, err := DefaultGenerator.Generate(c.field, c.ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range c.contains {
+			if !strings.Contains(out, s) {
+				t.Errorf("Expected %q in output.\nGot: %s", s, out)
+			}
+		}
+	}
+
+	if _, err := DefaultGenerator.Generate(Field("Unknown"), FieldContext{}); err == nil {
+		t.Fatal("Expected an error generating an unknown field")
+	}
+}
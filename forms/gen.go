@@ -1,5 +1,97 @@
 package forms
 
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"time"
+)
+
 func Form(val interface{}, g Generator) (string, error) {
 	return "", nil
 }
+
+//DefaultGenerator is a Generator that outputs simple html for every Field. Each
+//field is wrapped in a div with the class "field", and any error is output in a
+//span with the class "error".
+var DefaultGenerator Generator = htmlGenerator{}
+
+//htmlGenerator is the type of the DefaultGenerator.
+type htmlGenerator struct{}
+
+//localLayout is the layout of values expected by datetime-local inputs.
+const localLayout = "2006-01-02T15:04:05"
+
+//Generate implements the Generator interface.
+func (htmlGenerator) Generate(f Field, ctx FieldContext) (string, error) {
+	var (
+		buf   bytes.Buffer
+		name  = html.EscapeString(ctx.Name)
+		value = ""
+	)
+	if ctx.Value != nil {
+		value = fmt.Sprint(ctx.Value)
+	}
+
+	class := "field"
+	if ctx.Error != nil {
+		class += " error"
+	}
+	fmt.Fprintf(&buf, `<div class="%s"><label for="%s">%s</label>`, class, name, html.EscapeString(ctx.Label))
+
+	switch f {
+	case Text:
+		fmt.Fprintf(&buf, `<input type="text" id="%s" name="%s" value="%s">`, name, name, html.EscapeString(value))
+	case Password:
+		fmt.Fprintf(&buf, `<input type="password" id="%s" name="%s">`, name, name)
+	case Textarea:
+		fmt.Fprintf(&buf, `<textarea id="%s" name="%s">%s</textarea>`, name, name, html.EscapeString(value))
+	case Checkbox:
+		//the hidden input comes second so that an unchecked box still sends a
+		//value, and a checked box sends "true" first.
+		fmt.Fprintf(&buf, `<input type="checkbox" id="%s" name="%s" value="true"%s>`, name, name, attr(value == "true", "checked"))
+		fmt.Fprintf(&buf, `<input type="hidden" name="%s" value="false">`, name)
+	case Radio:
+		for _, item := range ctx.Choices {
+			fmt.Fprintf(&buf, `<label><input type="radio" name="%s" value="%s"%s> %s</label>`,
+				name, html.EscapeString(item.Value), attr(item.Value == value, "checked"), html.EscapeString(item.Label))
+		}
+	case Select:
+		fmt.Fprintf(&buf, `<select id="%s" name="%s">`, name, name)
+		for _, item := range ctx.Choices {
+			fmt.Fprintf(&buf, `<option value="%s"%s>%s</option>`,
+				html.EscapeString(item.Value), attr(item.Value == value, "selected"), html.EscapeString(item.Label))
+		}
+		buf.WriteString(`</select>`)
+	case DateTime:
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			value = t.UTC().Format(localLayout)
+		}
+		fmt.Fprintf(&buf, `<input type="datetime-local" step="1" id="%s" name="%s" value="%s">`, name, name, html.EscapeString(value))
+	case Autocomplete:
+		fmt.Fprintf(&buf, `<input type="text" class="autocomplete" id="%s" name="%s" value="%s" data-lookup="%s" autocomplete="off">`,
+			name, name, html.EscapeString(value), html.EscapeString(ctx.Source))
+	case File:
+		if value != "" {
+			fmt.Fprintf(&buf, `<span class="current">%s</span>`, html.EscapeString(value))
+		}
+		fmt.Fprintf(&buf, `<input type="file" id="%s" name="%s">`, name, name)
+	default:
+		return "", fmt.Errorf("Unknown field type: %s", f)
+	}
+
+	if ctx.Error != nil {
+		fmt.Fprintf(&buf, `<span class="error">%s</span>`, html.EscapeString(ctx.Error.Error()))
+	}
+	buf.WriteString(`</div>`)
+
+	return buf.String(), nil
+}
+
+//attr returns the attribute with a leading space if the condition is true.
+func attr(cond bool, name string) string {
+	if cond {
+		return " " + name
+	}
+	return ""
+}
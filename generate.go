@@ -0,0 +1,118 @@
+package admin
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/zeebo/admin/forms"
+	"reflect"
+)
+
+//GenerateForm returns the html for the fields of a form editing the object,
+//using the values and errors in the TemplateContext. Every field is rendered by
+//the Generator with the widget named by a widget tag on the field, a Select for
+//fields with choices, an Autocomplete for reference fields with a url in the
+//Lookups of the context, the widget given by its Codec, a Checkbox for bools,
+//and Text for everything else. Choices come from the object if it is a
+//forms.Chooser, or from a choices tag on the field. Labels, errors and the
+//labels of choices are translated with the Locale of the context. Nested
+//structs have their fields rendered with dot separated names as expected by
+//Load, prefixed by the Prefix of the context. The id field, unexported fields
+//and fields in Omit are skipped. If the Generator is nil,
+//forms.DefaultGenerator is used. It is intended to be called from GetForm:
+//
+//	func (t T) GetForm(ctx admin.TemplateContext) string {
+//		form, _ := admin.GenerateForm(t, ctx, nil)
+//		return form
+//	}
+func GenerateForm(obj interface{}, ctx TemplateContext, g forms.Generator) (string, error) {
+	if g == nil {
+		g = forms.DefaultGenerator
+	}
+
+	var buf bytes.Buffer
+	typ := indirectType(reflect.TypeOf(obj))
+	if err := generateFields(&buf, typ, ctx, "", chooserFor(obj), g); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+//generateFields writes the html for every field in the struct type to the
+//buffer, recursing into nested structs. The Values of the context are the
+//values for the struct, and prefix is the dot separated path to the struct.
+func generateFields(buf *bytes.Buffer, typ reflect.Type, ctx TemplateContext, prefix string, ch forms.Chooser, g forms.Generator) error {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" || isIdField(field) {
+			continue
+		}
+		ftyp, name := indirectType(field.Type), prefix+field.Name
+		if ctx.Omit[name] {
+			continue
+		}
+
+		//recurse into structs
+		if !isBasic(ftyp) {
+			nested := ctx
+			nested.Values, _ = ctx.Values[field.Name].(map[string]interface{})
+			if err := generateFields(buf, ftyp, nested, name+".", ch, g); err != nil {
+				return err
+			}
+			continue
+		}
+
+		choices, source := choicesFor(ch, field, name), ctx.Lookups[name]
+		html, err := g.Generate(widgetForField(field, ftyp, choices, source), forms.FieldContext{
+			Name:    ctx.Prefix + name,
+			Label:   ctx.Locale.T(field.Name),
+			Value:   ctx.Values[field.Name],
+			Error:   ctx.Locale.fieldError(ctx.Errors[name]),
+			Choices: ctx.Locale.items(choices),
+			Source:  source,
+		})
+		if err != nil {
+			return err
+		}
+		buf.WriteString(html)
+	}
+	return nil
+}
+
+//widgetForField returns the kind of form field used to edit the struct field,
+//respecting a widget tag, any choices for the field, and any lookup url for a
+//reference field.
+func widgetForField(field reflect.StructField, typ reflect.Type, choices []forms.Item, source string) forms.Field {
+	if w := field.Tag.Get("widget"); w != "" {
+		return forms.Field(w)
+	}
+	if choices != nil {
+		return forms.Select
+	}
+	if source != "" {
+		return forms.Autocomplete
+	}
+	return widgetFor(typ)
+}
+
+//widgetFor returns the kind of form field used to edit values of the type.
+func widgetFor(typ reflect.Type) forms.Field {
+	if c, ok := codecFor(typ); ok {
+		return c.Widget()
+	}
+	if typ.Kind() == reflect.Bool {
+		return forms.Checkbox
+	}
+	return forms.Text
+}
+
+//fieldError turns a value from a LoadingErrors or ValidationErrors map into an
+//error for a forms.FieldContext.
+func fieldError(v interface{}) error {
+	switch e := v.(type) {
+	case nil:
+		return nil
+	case error:
+		return e
+	}
+	return fmt.Errorf("%v", v)
+}
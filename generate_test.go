@@ -0,0 +1,48 @@
+package admin
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestGenerateForm(t *testing.T) {
+	type nested struct {
+		X string
+		Y struct {
+			Z bool
+		}
+		W T8
+	}
+
+	ctx := TemplateContext{
+		Values: map[string]interface{}{
+			"X": "hello",
+			"Y": map[string]interface{}{"Z": "true"},
+		},
+		Errors: map[string]interface{}{
+			"Y.Z": errors.New("bad bool"),
+		},
+	}
+
+	out, err := GenerateForm(nested{}, ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{
+		`name="X" value="hello"`,
+		`type="checkbox" id="Y.Z" name="Y.Z" value="true" checked`,
+		`bad bool`,
+		`name="W.Price"`,
+		`type="datetime-local" step="1" id="W.When"`,
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("Expected %q in output.\nGot: %s", s, out)
+		}
+	}
+
+	if strings.Contains(out, `name="W.ID"`) {
+		t.Errorf("Expected the id field to be skipped.\nGot: %s", out)
+	}
+}
@@ -0,0 +1,113 @@
+package admin
+
+import (
+	"sort"
+	"strings"
+)
+
+//ManagedCollection describes a managed database.collection for the index and
+//navigation with the names, description and icon from its Options. Key is the
+//database.collection used in urls.
+type ManagedCollection struct {
+	Key         string `json:"key"`
+	Name        string `json:"name"`
+	Plural      string `json:"plural"`
+	Description string `json:"description"`
+	Icon        string `json:"icon"`
+	Order       int    `json:"order"`
+}
+
+//Group is a heading on the index and in the navigation with the collections
+//listed under it, in order.
+type Group struct {
+	Name        string              `json:"name"`
+	Collections []ManagedCollection `json:"collections"`
+}
+
+//managedCollection returns the description of the database.collection from its
+//options. Name defaults to the collection name, and Plural to Name with an s.
+func managedCollection(key string, opt Options) ManagedCollection {
+	m := ManagedCollection{
+		Key:         key,
+		Name:        opt.Name,
+		Plural:      opt.Plural,
+		Description: opt.Description,
+		Icon:        opt.Icon,
+		Order:       opt.Order,
+	}
+	//collections are usually named in the plural already, so without a Name
+	//both are the collection name as before
+	coll := key[strings.Index(key, ".")+1:]
+	switch {
+	case m.Name == "":
+		m.Name = coll
+		if m.Plural == "" {
+			m.Plural = coll
+		}
+	case m.Plural == "":
+		m.Plural = m.Name + "s"
+	}
+	return m
+}
+
+//groupFor returns the group the collection is listed under: its Group, or its
+//database if it has none.
+func groupFor(key string, opt Options) string {
+	if opt.Group != "" {
+		return opt.Group
+	}
+	return key[:strings.Index(key, ".")]
+}
+
+//generateGroups puts every managed collection into its group. Collections are
+//sorted by Order and then Name, and groups by the lowest Order of their
+//collections and then name.
+func (a *Admin) generateGroups() {
+	byName := map[string]*Group{}
+	for key, info := range a.types {
+		name := groupFor(key, info.Options)
+		g, ok := byName[name]
+		if !ok {
+			g = &Group{Name: name}
+			byName[name] = g
+		}
+		g.Collections = append(g.Collections, managedCollection(key, info.Options))
+	}
+
+	a.groups = make([]Group, 0, len(byName))
+	for _, g := range byName {
+		sort.Slice(g.Collections, func(i, j int) bool {
+			ci, cj := g.Collections[i], g.Collections[j]
+			if ci.Order != cj.Order {
+				return ci.Order < cj.Order
+			}
+			if ci.Name != cj.Name {
+				return ci.Name < cj.Name
+			}
+			return ci.Key < cj.Key
+		})
+		a.groups = append(a.groups, *g)
+	}
+
+	//the collections are sorted, so the first has the lowest order
+	sort.Slice(a.groups, func(i, j int) bool {
+		oi, oj := a.groups[i].Collections[0].Order, a.groups[j].Collections[0].Order
+		if oi != oj {
+			return oi < oj
+		}
+		return a.groups[i].Name < a.groups[j].Name
+	})
+}
+
+//Display returns the description of the managed database.collection, for
+//showing its name in templates. Unknown collections get the default names.
+func (b BaseContext) Display(key string) ManagedCollection {
+	for _, g := range b.Groups {
+		for _, c := range g.Collections {
+			if c.Key == key {
+				return c
+			}
+		}
+	}
+	return managedCollection(key, Options{})
+}
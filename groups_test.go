@@ -0,0 +1,79 @@
+package admin
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGenerateGroups(t *testing.T) {
+	h := &Admin{
+		Renderer: &TestRenderer{},
+	}
+
+	h.Register(T{}, "shop.orders", &Options{Name: "order", Group: "Sales", Order: 2})
+	h.Register(T{}, "shop.customers", &Options{Name: "customer", Group: "Sales", Order: 1})
+	h.Register(T{}, "shop.products", &Options{Name: "product", Plural: "catalog", Group: "Catalog", Order: 3})
+	h.Register(T{}, "logs.requests", nil)
+	h.Register(T{}, "logs.errors", nil)
+	h.generateGroups()
+
+	var names [][]string
+	for _, g := range h.groups {
+		group := []string{g.Name}
+		for _, c := range g.Collections {
+			group = append(group, c.Key)
+		}
+		names = append(names, group)
+	}
+
+	expected := [][]string{
+		{"logs", "logs.errors", "logs.requests"},
+		{"Sales", "shop.customers", "shop.orders"},
+		{"Catalog", "shop.products"},
+	}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Unexpected groups.\nExpected %v\nGot %v", expected, names)
+	}
+
+	if p := h.groups[2].Collections[0].Plural; p != "catalog" {
+		t.Errorf("Expected the configured plural. Got %q", p)
+	}
+}
+
+func TestManagedCollectionDefaults(t *testing.T) {
+	m := managedCollection("shop.orders", Options{Description: "Placed orders", Icon: "cart.svg"})
+	expected := ManagedCollection{
+		Key:         "shop.orders",
+		Name:        "orders",
+		Plural:      "orders",
+		Description: "Placed orders",
+		Icon:        "cart.svg",
+	}
+	if m != expected {
+		t.Errorf("Expected %+v. Got %+v", expected, m)
+	}
+
+	if m := managedCollection("shop.box", Options{Name: "box", Plural: "boxes"}); m.Plural != "boxes" {
+		t.Errorf("Expected the configured plural. Got %q", m.Plural)
+	}
+
+	if g := groupFor("shop.orders", Options{}); g != "shop" {
+		t.Errorf("Expected the database as the group. Got %q", g)
+	}
+}
+
+func TestBaseContextDisplay(t *testing.T) {
+	ctx := BaseContext{Groups: []Group{{
+		Name: "Sales",
+		Collections: []ManagedCollection{
+			managedCollection("shop.orders", Options{Name: "order"}),
+		},
+	}}}
+
+	if c := ctx.Display("shop.orders"); c.Name != "order" || c.Plural != "orders" {
+		t.Errorf("Unexpected display for a managed collection: %+v", c)
+	}
+	if c := ctx.Display("shop.unknown"); c.Name != "unknown" || c.Key != "shop.unknown" {
+		t.Errorf("Unexpected display for an unknown collection: %+v", c)
+	}
+}
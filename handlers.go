@@ -1,11 +1,11 @@
 package admin
 
 import (
-	"fmt"
 	"github.com/zeebo/sign"
 	"launchpad.net/mgo/bson"
 	"math"
 	"net/http"
+	"net/url"
 	"path"
 	"reflect"
 	"strings"
@@ -27,7 +27,10 @@ func parseRequest(p string) (coll, id string) {
 
 func (a *Admin) baseContext(req *http.Request) (ctx BaseContext) {
 	ctx.Managed = a.index_cache
+	ctx.Groups = a.groups
 	ctx.Reverser = Reverser{a}
+	ctx.Locale = a.localeFor(req)
+	ctx.Theme = a.Theme.withDefaults()
 
 	if auth, ex := a.auth_cache[req]; ex {
 		ctx.Auth = &auth
@@ -36,6 +39,66 @@ func (a *Admin) baseContext(req *http.Request) (ctx BaseContext) {
 	return
 }
 
+//pageRenderer returns the renderer checked for the optional interface of a page:
+//the renderer a NegotiatingRenderer picks for the request, or the Renderer.
+func (a *Admin) pageRenderer(req *http.Request) Renderer {
+	if n, ok := a.Renderer.(NegotiatingRenderer); ok {
+		return n.pick(req)
+	}
+	return a.Renderer
+}
+
+//renderAudit presents the audit page with the Renderer if it is an
+//AuditRenderer, and with the default renderer otherwise. The other render
+//functions do the same for their pages.
+func (a *Admin) renderAudit(w http.ResponseWriter, req *http.Request, c AuditContext) {
+	if r, ok := a.pageRenderer(req).(AuditRenderer); ok {
+		r.Audit(w, req, c)
+		return
+	}
+	a.fallback.Audit(w, req, c)
+}
+
+func (a *Admin) renderHistory(w http.ResponseWriter, req *http.Request, c HistoryContext) {
+	if r, ok := a.pageRenderer(req).(HistoryRenderer); ok {
+		r.History(w, req, c)
+		return
+	}
+	a.fallback.History(w, req, c)
+}
+
+func (a *Admin) renderTrash(w http.ResponseWriter, req *http.Request, c TrashContext) {
+	if r, ok := a.pageRenderer(req).(TrashRenderer); ok {
+		r.Trash(w, req, c)
+		return
+	}
+	a.fallback.Trash(w, req, c)
+}
+
+func (a *Admin) renderBulk(w http.ResponseWriter, req *http.Request, c BulkContext) {
+	if r, ok := a.pageRenderer(req).(BulkRenderer); ok {
+		r.Bulk(w, req, c)
+		return
+	}
+	a.fallback.Bulk(w, req, c)
+}
+
+func (a *Admin) renderAction(w http.ResponseWriter, req *http.Request, c ObjectActionContext) {
+	if r, ok := a.pageRenderer(req).(ActionRenderer); ok {
+		r.Action(w, req, c)
+		return
+	}
+	a.fallback.Action(w, req, c)
+}
+
+func (a *Admin) renderWebhooks(w http.ResponseWriter, req *http.Request, c WebhookContext) {
+	if r, ok := a.pageRenderer(req).(WebhookRenderer); ok {
+		r.Webhooks(w, req, c)
+		return
+	}
+	a.fallback.Webhooks(w, req, c)
+}
+
 func (a *Admin) auth(w http.ResponseWriter, req *http.Request) {
 	action, n := parseRequest(req.URL.Path)
 
@@ -76,6 +139,7 @@ func (a *Admin) auth(w http.ResponseWriter, req *http.Request) {
 		as := AuthSession{
 			Username: resp.Username,
 			Key:      resp.Key,
+			Locale:   resp.Locale,
 		}
 		signer := sign.Signer{a.Key}
 
@@ -122,7 +186,7 @@ func (a *Admin) detail(w http.ResponseWriter, req *http.Request) {
 	c, t := a.collFor(coll), a.newType(coll)
 
 	//load into T
-	if err := c.Find(bson.M{"_id": bson.ObjectIdHex(id)}).One(t); err != nil {
+	if err := c.Find(a.liveQuery(coll, bson.M{"_id": bson.ObjectIdHex(id)})).One(t); err != nil {
 		if err.Error() == "Document not found" {
 			a.Renderer.NotFound(w, req)
 			return
@@ -132,7 +196,14 @@ func (a *Admin) detail(w http.ResponseWriter, req *http.Request) {
 	}
 
 	//create the values for the template
-	ctx, err := generateContext(t, nil)
+	ctx, err := a.generateContext(req, coll, t, nil)
+	if err != nil {
+		a.Renderer.InternalError(w, req, err)
+		return
+	}
+
+	//find everything that points at the object
+	related, err := a.related(coll, id)
 	if err != nil {
 		a.Renderer.InternalError(w, req, err)
 		return
@@ -147,6 +218,10 @@ func (a *Admin) detail(w http.ResponseWriter, req *http.Request) {
 			context: ctx,
 			logger:  a.logger,
 		},
+		Files:   filesIn(t),
+		Links:   a.referenceLinks(coll, t),
+		Related: related,
+		Actions: a.objectActions(req, coll, t),
 	})
 }
 
@@ -169,7 +244,7 @@ func (a *Admin) delete(w http.ResponseWriter, req *http.Request) {
 	c, t := a.collFor(coll), a.newType(coll)
 
 	//load into T
-	if err := c.Find(bson.M{"_id": bson.ObjectIdHex(id)}).One(t); err != nil {
+	if err := c.Find(a.liveQuery(coll, bson.M{"_id": bson.ObjectIdHex(id)})).One(t); err != nil {
 		if err.Error() == "Document not found" {
 			a.Renderer.NotFound(w, req)
 			return
@@ -187,15 +262,35 @@ func (a *Admin) delete(w http.ResponseWriter, req *http.Request) {
 	if req.Form.Get("_sure") == "yes" {
 		attempted = true
 
-		err = c.Remove(bson.M{"_id": bson.ObjectIdHex(id)})
+		err = a.deleteObject(req, coll, id, t)
 		success = err == nil
 	}
 
-	//create the values for the template
-	ctx, err := generateContext(t, nil)
-	if err != nil {
+	//create the values for the template. Keep err as the error removing.
+	var form = Form{
+		object: t,
+		logger: a.logger,
+	}
+	var errors map[string]interface{}
+	if herr, ok := err.(HookError); ok {
+		errors = herr.Errors
+	}
+	if ctx, err := a.generateContext(req, coll, t, errors); err != nil {
 		a.Renderer.InternalError(w, req, err)
 		return
+	} else {
+		form.context = ctx
+	}
+
+	//warn about anything that references the object
+	var deps []Dependent
+	if !success {
+		found, err := a.dependents(coll, id)
+		if err != nil {
+			a.Renderer.InternalError(w, req, err)
+			return
+		}
+		deps = found
 	}
 
 	a.Renderer.Delete(w, req, DeleteContext{
@@ -205,11 +300,8 @@ func (a *Admin) delete(w http.ResponseWriter, req *http.Request) {
 		Attempted:   attempted,
 		Success:     success,
 		Error:       err,
-		Form: Form{
-			object:  t,
-			context: ctx,
-			logger:  a.logger,
-		},
+		Form:        form,
+		Dependents:  deps,
 	})
 }
 
@@ -223,7 +315,19 @@ func (a *Admin) index(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	a.Renderer.Index(w, req, a.baseContext(req))
+	//only Renderers that present the dashboard need it computed
+	r, ok := a.pageRenderer(req).(DashboardRenderer)
+	if !ok {
+		a.Renderer.Index(w, req, a.baseContext(req))
+		return
+	}
+
+	ctx, err := a.dashboard(req)
+	if err != nil {
+		a.Renderer.InternalError(w, req, err)
+		return
+	}
+	r.Dashboard(w, req, ctx)
 }
 
 //Presents a list of objects in a collection matching filtering/sorting criteria
@@ -241,20 +345,26 @@ func (a *Admin) list(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	c := a.collFor(coll)
+	//bulk actions are posted to the list
+	if req.Method == "POST" {
+		a.bulk(w, req, coll)
+		return
+	}
+
+	c, q := a.collFor(coll), a.liveQuery(coll, nil)
 
 	//TODO: make this load into a map[string]interface{} instead
 	//to reduce the amount of reflection we need to do. We can't get
 	//objects that way though so see if thats an issue.
 
-	total, err := c.Find(nil).Count()
+	total, err := c.Find(q).Count()
 	if err != nil {
 		a.Renderer.InternalError(w, req, err)
 	}
 
 	//grab the data
 	var items []interface{}
-	iter, page, numpage := listParse(c, req.URL.Query())
+	iter, page, numpage := listParse(c, q, req.URL.Query())
 	for {
 		t := a.newType(coll)
 		if !iter.Next(t) {
@@ -279,8 +389,14 @@ func (a *Admin) list(w http.ResponseWriter, req *http.Request) {
 	}
 
 	//make the values :(
+	loc := a.localeFor(req)
 	values := make([][]string, len(items))
+	files := make([]map[string]File, len(items))
+	links := make([]map[string]string, len(items))
+	rowIds := make([]string, len(items))
 	for i, obj := range items {
+		rowIds[i] = Reverser{a}.idFor(obj)
+
 		val, err := indirect(reflect.ValueOf(obj))
 		if err != nil {
 			a.Renderer.InternalError(w, req, err)
@@ -288,20 +404,29 @@ func (a *Admin) list(w http.ResponseWriter, req *http.Request) {
 		}
 
 		values[i] = make([]string, len(ids))
+		files[i] = map[string]File{}
+		links[i] = map[string]string{}
+		refs := a.referenceLinks(coll, obj)
 
 		for j, idx := range ids {
-			var data string
-
-			//TODO: make things that involve hexable into a function that gets
-			//called.
-			switch item := val.Field(idx).Interface().(type) {
-			case hexable:
-				data = item.Hex()
-			default:
-				data = fmt.Sprint(item)
+			values[i][j] = loc.format(val.Field(idx))
+
+			//display the label for fields with choices
+			if items := choicesFor(chooserFor(obj), typ.Field(idx), columns[j]); items != nil {
+				values[i][j] = loc.T(choiceLabel(items, formatValue(val.Field(idx))))
+			}
+
+			//link any references
+			if link, ok := refs[columns[j]]; ok {
+				links[i][columns[j]] = link
 			}
 
-			values[i][j] = data
+			//grab any files for thumbnails
+			if f, err := indirect(val.Field(idx)); err == nil && f.Type() == fileType {
+				if file := f.Interface().(File); file.ID != "" {
+					files[i][columns[j]] = file
+				}
+			}
 		}
 	}
 
@@ -315,6 +440,10 @@ func (a *Admin) list(w http.ResponseWriter, req *http.Request) {
 		Collection:  coll,
 		Columns:     columns,
 		Values:      values,
+		Files:       files,
+		Links:       links,
+		IDs:         rowIds,
+		Actions:     a.bulkActions(coll),
 		Objects:     items,
 		Pagination: Pagination{
 			Pages:       pages,
@@ -343,7 +472,7 @@ func (a *Admin) update(w http.ResponseWriter, req *http.Request) {
 	c, t := a.collFor(coll), a.newType(coll)
 
 	//grab the data
-	if err := c.Find(bson.M{"_id": bson.ObjectIdHex(id)}).One(t); err != nil {
+	if err := c.Find(a.liveQuery(coll, bson.M{"_id": bson.ObjectIdHex(id)})).One(t); err != nil {
 		if err.Error() == "Document not found" {
 			a.Renderer.NotFound(w, req)
 			return
@@ -352,40 +481,169 @@ func (a *Admin) update(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	var attempted, success bool
+	//remember how the object looked to detect conflicting updates
+	etag, err := a.etag(coll, t)
+	if err != nil {
+		a.Renderer.InternalError(w, req, err)
+		return
+	}
+	match, err := a.matchQuery(coll, bson.ObjectIdHex(id), t)
+	if err != nil {
+		a.Renderer.InternalError(w, req, err)
+		return
+	}
+	stored, err := a.storedValues(coll, t)
+	if err != nil {
+		a.Renderer.InternalError(w, req, err)
+		return
+	}
+
+	var attempted, success, conflict, saved bool
 	var errors map[string]interface{}
+	var children [][]*inlineChild
+	var current Formable
+	var compare []Change
 	if req.Method == "POST" {
 		attempted = true
+		if err := a.limitBody(w, req, coll, id); err != nil {
+			a.Renderer.InternalError(w, req, err)
+			return
+		}
 
-		var err error
-		errors, err = performLoading(req, t)
+		//drop whichever uploads end up unused
+		files := filesIn(t)
+		defer func() { a.cleanupFiles(files, filesIn(t), saved) }()
+
+		//grab the values before loading for the audit log
+		before, err := formValues(t)
+		if err != nil {
+			a.Renderer.InternalError(w, req, err)
+			return
+		}
+
+		errors, err = a.performLoading(req, coll, t)
+		if err != nil {
+			a.Renderer.InternalError(w, req, err)
+			return
+		}
+
+		//load the inlines even with errors so they're reported together
+		var ierrs map[string]interface{}
+		children, ierrs, err = a.loadInlines(req, coll, id)
 		if err != nil {
 			a.Renderer.InternalError(w, req, err)
 			return
 		}
+		defer a.cleanupInlines(children)
+		errors = mergeErrors(errors, ierrs)
 		if errors != nil && len(errors) > 0 {
 			goto render
 		}
 
-		if err := c.Update(bson.M{"_id": bson.ObjectIdHex(id)}, t); err != nil {
+		//give the type the last word, and then the children
+		if errors = a.beforeSave(req, coll, t); len(errors) > 0 {
+			goto render
+		}
+		if errors = a.beforeSaveInlines(req, coll, children); len(errors) > 0 {
+			goto render
+		}
+
+		//make sure nobody saved the object since the form was loaded, or in
+		//the meantime
+		if a.staleEtag(coll, req.Form.Get(etagKey), etag) {
+			conflict = true
+		} else if err := a.bumpVersion(coll, t, etag); err != nil {
+			a.Renderer.InternalError(w, req, err)
+			return
+		} else if err := a.partialUpdate(coll, match, stored, t); err != nil {
+			if err.Error() != "Document not found" {
+				a.Renderer.InternalError(w, req, err)
+				return
+			}
+			conflict = true
+		} else {
+			saved = true
+		}
+
+		//show them what it looks like now
+		if conflict {
+			current = a.newType(coll)
+			if err := c.Find(a.liveQuery(coll, bson.M{"_id": bson.ObjectIdHex(id)})).One(current); err != nil {
+				if err.Error() == "Document not found" {
+					a.Renderer.NotFound(w, req)
+					return
+				}
+				a.Renderer.InternalError(w, req, err)
+				return
+			}
+			if etag, err = a.etag(coll, current); err != nil {
+				a.Renderer.InternalError(w, req, err)
+				return
+			}
+
+			//line their version up with what was submitted
+			theirs, err := formValues(current)
+			if err != nil {
+				a.Renderer.InternalError(w, req, err)
+				return
+			}
+			submitted, err := formValues(t)
+			if err != nil {
+				a.Renderer.InternalError(w, req, err)
+				return
+			}
+			compare = compareValues(theirs, submitted)
+			goto render
+		}
+
+		//a child that conflicts is shown with its error, like invalid ones
+		ok, err := a.saveInlines(req, coll, id, children)
+		if err != nil {
+			a.Renderer.InternalError(w, req, err)
+			return
+		}
+		success = ok
+		a.afterSave(req, coll, t)
+
+		after, _ := formValues(t)
+		a.audit(req, ActionUpdate, coll, id, before, after)
+		a.snapshot(req, coll, id, after)
+
+		//the next update starts from what was just saved
+		if etag, err = a.etag(coll, t); err != nil {
 			a.Renderer.InternalError(w, req, err)
 			return
 		}
-		success = true
 	}
 
 render:
 	var form = Form{
 		object: t,
 		logger: a.logger,
+		hidden: url.Values{etagKey: {etag}},
 	}
-	if ctx, err := generateContext(t, errors); err != nil {
+	if ctx, err := a.generateContext(req, coll, t, errors); err != nil {
 		a.Renderer.InternalError(w, req, err)
 		return
 	} else {
 		form.context = ctx
 	}
 
+	//show the children as submitted if they weren't saved
+	if !attempted || success {
+		found, err := a.queryInlines(coll, id)
+		if err != nil {
+			a.Renderer.InternalError(w, req, err)
+			return
+		}
+		children = found
+	}
+	inlines, err := a.inlineSets(req, coll, children)
+	if err != nil {
+		a.Renderer.InternalError(w, req, err)
+		return
+	}
+
 	a.Renderer.Update(w, req, UpdateContext{
 		BaseContext: a.baseContext(req),
 		Collection:  coll,
@@ -393,6 +651,11 @@ render:
 		Attempted:   attempted,
 		Success:     success,
 		Form:        form,
+		Inlines:     inlines,
+		Conflict:    conflict,
+		Current:     current,
+		Compare:     compare,
+		Etag:        etag,
 	})
 }
 
@@ -413,26 +676,53 @@ func (a *Admin) create(w http.ResponseWriter, req *http.Request) {
 
 	c, t := a.collFor(coll), a.newType(coll)
 
-	var attempted, success bool
+	var attempted, success, saved bool
 	var errors map[string]interface{}
+	children := make([][]*inlineChild, len(a.types[coll].Options.Inlines))
 	if req.Method == "POST" {
 		attempted = true
+		if err := a.limitBody(w, req, coll, ""); err != nil {
+			a.Renderer.InternalError(w, req, err)
+			return
+		}
+
+		//drop the uploads if the object isn't saved
+		defer func() { a.cleanupFiles(nil, filesIn(t), saved) }()
 
 		var err error
-		errors, err = performLoading(req, t)
+		errors, err = a.performLoading(req, coll, t)
+		if err != nil {
+			a.Renderer.InternalError(w, req, err)
+			return
+		}
+
+		//load the inlines even with errors so they're reported together
+		var ierrs map[string]interface{}
+		children, ierrs, err = a.loadInlines(req, coll, "")
 		if err != nil {
 			a.Renderer.InternalError(w, req, err)
 			return
 		}
+		defer a.cleanupInlines(children)
+		errors = mergeErrors(errors, ierrs)
 		if errors != nil && len(errors) > 0 {
 			goto render
 		}
 
+		//give the type the last word, and then the children
+		if errors = a.beforeSave(req, coll, t); len(errors) > 0 {
+			goto render
+		}
+		if errors = a.beforeSaveInlines(req, coll, children); len(errors) > 0 {
+			goto render
+		}
+
 		id, err := c.Upsert(d{"_id": ""}, t)
 		if err != nil {
 			a.Renderer.InternalError(w, req, err)
 			return
 		}
+		saved = true
 
 		//lets grab the thing back out from the database
 		if err = c.Find(bson.M{"_id": id}).One(t); err != nil {
@@ -440,7 +730,23 @@ func (a *Admin) create(w http.ResponseWriter, req *http.Request) {
 			return
 		}
 
+		//now that the parent has an id the children can point at it
+		parent := Reverser{a}.idFor(t)
+		if _, err := a.saveInlines(req, coll, parent, children); err != nil {
+			a.Renderer.InternalError(w, req, err)
+			return
+		}
+		if children, err = a.queryInlines(coll, parent); err != nil {
+			a.Renderer.InternalError(w, req, err)
+			return
+		}
+
 		success = true
+		a.afterSave(req, coll, t)
+
+		after, _ := formValues(t)
+		a.audit(req, ActionCreate, coll, parent, nil, after)
+		a.snapshot(req, coll, parent, after)
 	}
 
 render:
@@ -449,7 +755,7 @@ render:
 		logger: a.logger,
 	}
 	if attempted {
-		if ctx, err := generateContext(t, errors); err != nil {
+		if ctx, err := a.generateContext(req, coll, t, errors); err != nil {
 			a.Renderer.InternalError(w, req, err)
 			return
 		} else {
@@ -462,33 +768,97 @@ render:
 		}
 
 		form.context = TemplateContext{
-			Values: val,
-			Errors: errors,
+			Values:  val,
+			Errors:  errors,
+			Lookups: a.lookups(coll),
+			Omit:    a.omitted(coll),
+			Locale:  a.localeFor(req),
 		}
 	}
 
+	inlines, err := a.inlineSets(req, coll, children)
+	if err != nil {
+		a.Renderer.InternalError(w, req, err)
+		return
+	}
+
 	a.Renderer.Create(w, req, CreateContext{
 		BaseContext: a.baseContext(req),
 		Collection:  coll,
 		Attempted:   attempted,
 		Success:     success,
 		Form:        form,
+		Inlines:     inlines,
 	})
 }
 
-//performLoading is a helper function that loads and validates the form, returning
-//any errors from the two steps. It respects if the type is a Loader.
-func performLoading(req *http.Request, t Formable) (errors map[string]interface{}, err error) {
-	//TODO: files!
-	err = req.ParseForm()
+//deleteObject deletes the object with the formatted id that was loaded into t,
+//moving it to the trash if the collection uses soft deletes, and records it.
+func (a *Admin) deleteObject(req *http.Request, coll, id string, t Formable) error {
+	q, err := a.idQuery(coll, id)
 	if err != nil {
-		return
+		return err
+	}
+	if err := a.beforeDelete(req, coll, t); err != nil {
+		return err
+	}
+	if err := a.removeObject(coll, q, t); err != nil {
+		return err
+	}
+	a.afterDelete(req, coll, t)
+
+	before, _ := formValues(t)
+	a.audit(req, ActionDelete, coll, id, before, nil)
+	return nil
+}
+
+//removeObject moves the document with the id that was loaded into t to the
+//trash if the collection uses soft deletes, and otherwise removes it along with
+//its uploaded files. Trashed documents keep their files until they're purged.
+func (a *Admin) removeObject(coll string, id, t interface{}) error {
+	if a.types[coll].Options.SoftDelete {
+		return a.moveToTrash(coll, id)
 	}
+	if err := a.collFor(coll).Remove(bson.M{"_id": id}); err != nil {
+		return err
+	}
+	a.deleteFiles(t)
+	return nil
+}
+
+//maxMemory is the number of bytes of a multipart form kept in memory before
+//files are stored on disk.
+const maxMemory = 32 << 20 //32MB
 
+//performLoading is a helper function that parses the request and loads and
+//validates its form with loadObject. The handler must pass the files before and
+//after to cleanupFiles once it knows if the object was saved.
+func (a *Admin) performLoading(req *http.Request, coll string, t Formable) (map[string]interface{}, error) {
+	err := req.ParseMultipartForm(maxMemory)
+	if err == http.ErrNotMultipart {
+		err = nil
+	}
+	if err != nil {
+		return nil, bodyError(err)
+	}
+	return a.loadObject(req, req.Form, coll, "", t)
+}
+
+//loadObject loads the values into the object and validates it, returning any
+//errors from the two steps. It respects if the type is a Loader. For non
+//Loaders, references are checked and files uploaded in the request under the
+//prefix are stored in the BlobStore.
+func (a *Admin) loadObject(req *http.Request, form url.Values, coll, prefix string, t Formable) (errors map[string]interface{}, err error) {
 	if l, ok := t.(Loader); ok {
-		errors, err = l.Load(req.Form)
+		errors, err = l.Load(form)
 	} else {
-		errors, err = Load(req.Form, t)
+		errors, err = Load(form, t)
+		if err == nil && len(errors) == 0 {
+			errors, err = a.loadReferences(coll, t)
+		}
+		if err == nil && len(errors) == 0 {
+			errors, err = a.loadFiles(req, coll, prefix, t)
+		}
 	}
 
 	//do we have loading errors?
@@ -500,24 +870,30 @@ func performLoading(req *http.Request, t Formable) (errors map[string]interface{
 	return
 }
 
-//generateContext takes a value that should be filled in, and some errors generated
-//while filling it in and returns a TemplateContext for rendering a Form, and
-//any errors attempting to do so.
-func generateContext(t Formable, errors map[string]interface{}) (TemplateContext, error) {
-	if l, ok := t.(Loader); ok {
-		return TemplateContext{
-			Values: l.GenerateValues(),
-			Errors: errors,
-		}, nil
+//omitted returns the fields of the collection left out of forms because only
+//the admin changes them.
+func (a *Admin) omitted(coll string) map[string]bool {
+	omit := map[string]bool{}
+	if name := a.types[coll].Options.VersionField; name != "" {
+		omit[name] = true
 	}
+	return omit
+}
 
-	values, err := CreateValues(t)
+//generateContext takes a value that should be filled in, and some errors generated
+//while filling it in and returns a TemplateContext for rendering a Form in the
+//locale of the request, and any errors attempting to do so.
+func (a *Admin) generateContext(req *http.Request, coll string, t Formable, errors map[string]interface{}) (TemplateContext, error) {
+	values, err := formValues(t)
 	if err != nil {
 		return TemplateContext{}, err
 	}
 
 	return TemplateContext{
-		Values: values,
-		Errors: errors,
+		Values:  values,
+		Errors:  errors,
+		Lookups: a.lookups(coll),
+		Omit:    a.omitted(coll),
+		Locale:  a.localeFor(req),
 	}, nil
 }
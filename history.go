@@ -0,0 +1,288 @@
+package admin
+
+import (
+	"launchpad.net/mgo"
+	"launchpad.net/mgo/bson"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+//Version is a snapshot of a document saved through the admin. Number counts up
+//from 1 for every document, and Values are the values of the document as
+//returned by CreateValues, or GenerateValues for Loaders.
+type Version struct {
+	ID         bson.ObjectId `bson:"_id,omitempty"`
+	Collection string
+	Object     string
+	Number     int
+	User       string
+	Time       time.Time
+	Values     map[string]interface{}
+}
+
+//VersionStore keeps the Versions of documents. Versions returns every Version
+//of the document with the formatted id in the database/collection, oldest
+//first.
+type VersionStore interface {
+	Save(Version) error
+	Versions(coll, id string) ([]Version, error)
+}
+
+//CollectionVersions is a VersionStore that keeps Versions as documents in a
+//mongo collection.
+type CollectionVersions struct {
+	Session    *mgo.Session
+	DB         string
+	Collection string
+}
+
+//coll returns the mgo.Collection for the versions.
+func (c CollectionVersions) coll() *mgo.Collection {
+	return c.Session.DB(c.DB).C(c.Collection)
+}
+
+//Save implements the VersionStore interface.
+func (c CollectionVersions) Save(v Version) error {
+	return c.coll().Insert(v)
+}
+
+//Versions implements the VersionStore interface.
+func (c CollectionVersions) Versions(coll, id string) ([]Version, error) {
+	var versions []Version
+	iter := c.coll().Find(bson.M{"collection": coll, "object": id}).Sort(bson.M{"number": 1}).Iter()
+	for {
+		var v Version
+		if !iter.Next(&v) {
+			break
+		}
+		versions = append(versions, v)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+//findVersion returns the Version with the number out of the versions.
+func findVersion(versions []Version, n int) (Version, bool) {
+	for _, v := range versions {
+		if v.Number == n {
+			return v, true
+		}
+	}
+	return Version{}, false
+}
+
+//snapshot saves the values of the object with the formatted id as a new
+//Version, if there is a VersionStore. The write already happened, so errors are
+//only logged.
+func (a *Admin) snapshot(req *http.Request, coll, id string, values map[string]interface{}) {
+	if a.Versions == nil {
+		return
+	}
+
+	versions, err := a.Versions.Versions(coll, id)
+	if err != nil {
+		a.logger.Printf("Error finding versions: %s", err)
+		return
+	}
+
+	n := 1
+	if len(versions) > 0 {
+		n = versions[len(versions)-1].Number + 1
+	}
+
+	err = a.Versions.Save(Version{
+		Collection: coll,
+		Object:     id,
+		Number:     n,
+		User:       a.username(req),
+		Time:       time.Now(),
+		Values:     values,
+	})
+	if err != nil {
+		a.logger.Printf("Error saving version: %s", err)
+	}
+}
+
+//versionForm turns the values of a Version of a document of the type back into
+//the form that would have saved them. Files can only be uploaded, so File
+//fields are left out and keep their current value.
+func versionForm(typ reflect.Type, values map[string]interface{}) url.Values {
+	flat := map[string]string{}
+	flattenValues(flat, values, "")
+
+	form := url.Values{}
+	for name, val := range flat {
+		if ftyp, ok := fieldType(typ, name); ok && indirectType(ftyp) == fileType {
+			continue
+		}
+		form.Set(name, val)
+	}
+	return form
+}
+
+//revert loads the Version into the object with loadObject, the same way a
+//submitted form is loaded, returning any errors loading and validating it.
+func (a *Admin) revert(req *http.Request, coll string, t Formable, v Version) (map[string]interface{}, error) {
+	return a.loadObject(req, versionForm(a.types[coll].Type, v.Values), coll, "", t)
+}
+
+//Presents the saved versions of an object, the differences between two of
+//them given by the from and to parameters, and reverts to the version given by
+//the revert parameter when posted
+func (a *Admin) history(w http.ResponseWriter, req *http.Request) {
+	coll, id := parseRequest(req.URL.Path)
+
+	//ensure we have both a collection and an id, and somewhere to find versions
+	if coll == "" || id == "" || a.Versions == nil {
+		a.Renderer.NotFound(w, req)
+		return
+	}
+
+	//make sure we know about the requested collection
+	if !a.hasType(coll) {
+		a.Renderer.NotFound(w, req)
+		return
+	}
+
+	c, t := a.collFor(coll), a.newType(coll)
+
+	//grab the data
+	if err := c.Find(a.liveQuery(coll, bson.M{"_id": bson.ObjectIdHex(id)})).One(t); err != nil {
+		if err.Error() == "Document not found" {
+			a.Renderer.NotFound(w, req)
+			return
+		}
+		a.Renderer.InternalError(w, req, err)
+		return
+	}
+
+	versions, err := a.Versions.Versions(coll, id)
+	if err != nil {
+		a.Renderer.InternalError(w, req, err)
+		return
+	}
+
+	var attempted, success, conflict bool
+	var errors map[string]interface{}
+	if req.Method == "POST" {
+		attempted = true
+
+		req.ParseForm()
+		n, _ := strconv.Atoi(req.Form.Get("revert"))
+		v, ok := findVersion(versions, n)
+		if !ok {
+			a.Renderer.NotFound(w, req)
+			return
+		}
+
+		before, err := formValues(t)
+		if err != nil {
+			a.Renderer.InternalError(w, req, err)
+			return
+		}
+		etag, err := a.etag(coll, t)
+		if err != nil {
+			a.Renderer.InternalError(w, req, err)
+			return
+		}
+		match, err := a.matchQuery(coll, bson.ObjectIdHex(id), t)
+		if err != nil {
+			a.Renderer.InternalError(w, req, err)
+			return
+		}
+		stored, err := a.storedValues(coll, t)
+		if err != nil {
+			a.Renderer.InternalError(w, req, err)
+			return
+		}
+
+		errors, err = a.revert(req, coll, t, v)
+		if err != nil {
+			a.Renderer.InternalError(w, req, err)
+			return
+		}
+		if len(errors) > 0 {
+			goto render
+		}
+		if errors = a.beforeSave(req, coll, t); len(errors) > 0 {
+			goto render
+		}
+
+		//reverting is a new version of the object
+		if err := a.bumpVersion(coll, t, etag); err != nil {
+			a.Renderer.InternalError(w, req, err)
+			return
+		}
+		if err := a.partialUpdate(coll, match, stored, t); err != nil {
+			if err.Error() != "Document not found" {
+				a.Renderer.InternalError(w, req, err)
+				return
+			}
+
+			//someone saved it in the meantime, so show them what it is now
+			conflict, t = true, a.newType(coll)
+			if err := c.Find(a.liveQuery(coll, bson.M{"_id": bson.ObjectIdHex(id)})).One(t); err != nil {
+				if err.Error() == "Document not found" {
+					a.Renderer.NotFound(w, req)
+					return
+				}
+				a.Renderer.InternalError(w, req, err)
+				return
+			}
+			if versions, err = a.Versions.Versions(coll, id); err != nil {
+				a.Renderer.InternalError(w, req, err)
+				return
+			}
+			goto render
+		}
+		success = true
+		a.afterSave(req, coll, t)
+
+		after, _ := formValues(t)
+		a.audit(req, ActionUpdate, coll, id, before, after)
+		a.snapshot(req, coll, id, after)
+
+		//grab the versions again to include the revert
+		if versions, err = a.Versions.Versions(coll, id); err != nil {
+			a.Renderer.InternalError(w, req, err)
+			return
+		}
+	}
+
+render:
+	//compute the differences if asked
+	query := req.URL.Query()
+	from, to := grabInt(query, "from", 0), grabInt(query, "to", 0)
+
+	var diff []Change
+	if fv, ok := findVersion(versions, from); ok {
+		if tv, ok := findVersion(versions, to); ok {
+			diff = diffValues(fv.Values, tv.Values)
+		}
+	}
+
+	//newest first
+	list := make([]Version, len(versions))
+	for i, v := range versions {
+		list[len(versions)-1-i] = v
+	}
+
+	a.renderHistory(w, req, HistoryContext{
+		BaseContext: a.baseContext(req),
+		Collection:  coll,
+		Object:      t,
+		Versions:    list,
+		From:        from,
+		To:          to,
+		Diff:        diff,
+		Attempted:   attempted,
+		Success:     success,
+		Conflict:    conflict,
+		Errors:      errors,
+	})
+}
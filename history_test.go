@@ -0,0 +1,112 @@
+package admin
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+//memoryVersions is a VersionStore that keeps versions in memory.
+type memoryVersions []Version
+
+func (m *memoryVersions) Save(v Version) error {
+	*m = append(*m, v)
+	return nil
+}
+
+func (m *memoryVersions) Versions(coll, id string) ([]Version, error) {
+	var versions []Version
+	for _, v := range *m {
+		if v.Collection == coll && v.Object == id {
+			versions = append(versions, v)
+		}
+	}
+	return versions, nil
+}
+
+func TestSnapshot(t *testing.T) {
+	store := &memoryVersions{}
+	h := &Admin{
+		Versions: store,
+		logger:   log.New(ioutil.Discard, "", 0),
+	}
+	req, _ := http.NewRequest("POST", "/update/admin_test.T10/1", nil)
+
+	h.snapshot(req, "admin_test.T10", "1", map[string]interface{}{"Status": "draft"})
+	h.snapshot(req, "admin_test.T10", "2", map[string]interface{}{"Status": "draft"})
+	h.snapshot(req, "admin_test.T10", "1", map[string]interface{}{"Status": "published"})
+
+	versions, _ := store.Versions("admin_test.T10", "1")
+	if len(versions) != 2 || versions[0].Number != 1 || versions[1].Number != 2 {
+		t.Fatalf("Expected versions 1 and 2. Got %+v", versions)
+	}
+	if v, ok := findVersion(versions, 2); !ok || v.Values["Status"] != "published" {
+		t.Fatalf("Wrong version 2: %+v", v)
+	}
+	if _, ok := findVersion(versions, 3); ok {
+		t.Fatal("Found a version that doesn't exist")
+	}
+}
+
+func TestVersionForm(t *testing.T) {
+	values := map[string]interface{}{
+		"ID":     "4f07c34779bf562daff8640c",
+		"Name":   "foo",
+		"Avatar": "pic.png",
+		"Nested": map[string]interface{}{"Doc": "doc.pdf"},
+	}
+
+	expected := url.Values{
+		"ID":   {"4f07c34779bf562daff8640c"},
+		"Name": {"foo"},
+	}
+	if got := versionForm(reflect.TypeOf(T9{}), values); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected %v. Got %v", expected, got)
+	}
+}
+
+func TestRevert(t *testing.T) {
+	h := &Admin{}
+	h.Register(T10{}, "admin_test.T10", nil)
+	req, _ := http.NewRequest("POST", "/history/admin_test.T10/1", nil)
+
+	x := &T10{Status: "published", Level: 2}
+	errs, err := h.revert(req, "admin_test.T10", x, Version{Values: map[string]interface{}{
+		"Status": "draft",
+		"Level":  "1",
+		"Inner":  map[string]interface{}{"Color": "red"},
+	}})
+	if err != nil || len(errs) > 0 {
+		t.Fatalf("Error reverting: %v %v", err, errs)
+	}
+	if x.Status != "draft" || x.Level != 1 || x.Inner.Color != "red" {
+		t.Fatalf("Version not loaded: %+v", x)
+	}
+
+	errs, err = h.revert(req, "admin_test.T10", x, Version{Values: map[string]interface{}{
+		"Status": "archived",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !compareErrs(LoadingErrors(errs), []string{"Status"}) {
+		t.Fatalf("Expected an error reverting to an invalid choice. Got %v", errs)
+	}
+}
+
+func TestRevertLoaderCalled(t *testing.T) {
+	h := &Admin{}
+	h.Register(T5{}, "admin_test.T5", nil)
+	req, _ := http.NewRequest("POST", "/history/admin_test.T5/1", nil)
+
+	defer func() {
+		if err := recover(); err != "called l" {
+			t.Fatalf("Expected the Loader to be called. Got %v", err)
+		}
+	}()
+
+	h.revert(req, "admin_test.T5", &T5{}, Version{Values: map[string]interface{}{"X": "foo"}})
+}
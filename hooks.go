@@ -0,0 +1,88 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+//The hooks are called for writes made to a document through its own pages and
+//bulk actions, and for children saved or deleted along with their parent by an
+//Inline. Trashed documents purged for good don't call them.
+
+//BeforeSaver lets a type normalize itself or refuse to be saved when the admin
+//creates, updates or reverts it. BeforeSave is called after the form is loaded
+//and validated, just before the write, and any errors are shown in the form as
+//if Validate returned them. See LoadingErrors for what the keys must be.
+type BeforeSaver interface {
+	BeforeSave(ActionContext) ValidationErrors
+}
+
+//AfterSaver lets a type react to being saved by the admin, for example by
+//invalidating caches. AfterSave is called on the saved object once the write
+//succeeds.
+type AfterSaver interface {
+	AfterSave(ActionContext)
+}
+
+//BeforeDeleter lets a type refuse to be deleted by the admin. Any errors
+//returned by BeforeDelete abort the delete and are shown in the form.
+type BeforeDeleter interface {
+	BeforeDelete(ActionContext) ValidationErrors
+}
+
+//AfterDeleter lets a type react to being deleted by the admin. AfterDelete is
+//called on the deleted object once the delete succeeds, including when it is
+//moved to the trash.
+type AfterDeleter interface {
+	AfterDelete(ActionContext)
+}
+
+//HookError is the error returned when a BeforeDeleter refuses a delete. Errors
+//is what BeforeDelete returned.
+type HookError struct {
+	Errors ValidationErrors
+}
+
+func (h HookError) Error() string {
+	msgs := make([]string, 0, len(h.Errors))
+	for key, err := range h.Errors {
+		msgs = append(msgs, fmt.Sprintf("%s: %v", key, err))
+	}
+	sort.Strings(msgs)
+	return "Refused: " + strings.Join(msgs, ", ")
+}
+
+//beforeSave runs the BeforeSave hook on the object if it has one.
+func (a *Admin) beforeSave(req *http.Request, coll string, t Formable) ValidationErrors {
+	if h, ok := t.(BeforeSaver); ok {
+		return h.BeforeSave(a.actionContext(req, coll))
+	}
+	return nil
+}
+
+//afterSave runs the AfterSave hook on the object if it has one.
+func (a *Admin) afterSave(req *http.Request, coll string, t Formable) {
+	if h, ok := t.(AfterSaver); ok {
+		h.AfterSave(a.actionContext(req, coll))
+	}
+}
+
+//beforeDelete runs the BeforeDelete hook on the object if it has one, returning
+//a HookError if it refused.
+func (a *Admin) beforeDelete(req *http.Request, coll string, t Formable) error {
+	if h, ok := t.(BeforeDeleter); ok {
+		if errs := h.BeforeDelete(a.actionContext(req, coll)); len(errs) > 0 {
+			return HookError{errs}
+		}
+	}
+	return nil
+}
+
+//afterDelete runs the AfterDelete hook on the object if it has one.
+func (a *Admin) afterDelete(req *http.Request, coll string, t Formable) {
+	if h, ok := t.(AfterDeleter); ok {
+		h.AfterDelete(a.actionContext(req, coll))
+	}
+}
@@ -0,0 +1,142 @@
+package admin
+
+import (
+	"launchpad.net/mgo/bson"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestHookError(t *testing.T) {
+	err := HookError{ValidationErrors{
+		"Name":  "is still in use",
+		"Inner": "is locked",
+	}}
+
+	expected := "Refused: Inner: is locked, Name: is still in use"
+	if got := err.Error(); got != expected {
+		t.Fatalf("Expected %q. Got %q", expected, got)
+	}
+}
+
+func TestHooksCalled(t *testing.T) {
+	r := &TestRenderer{}
+	h := &Admin{
+		Session:  session,
+		Renderer: r,
+		Versions: &memoryVersions{},
+	}
+	h.Register(T14{}, "admin_test.T14", nil)
+
+	c := session.DB("admin_test").C("T14")
+	c.RemoveAll(nil)
+	t.Cleanup(func() { c.RemoveAll(nil) })
+
+	hookCalls = nil
+	expect := func(what string, hooks ...string) {
+		t.Helper()
+		if !reflect.DeepEqual(hookCalls, hooks) {
+			t.Fatalf("Expected %v on %s. Got %v", hooks, what, hookCalls)
+		}
+		hookCalls = nil
+	}
+	count := func() int {
+		n, err := c.Count()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	rename := func(id bson.ObjectId, name string) {
+		if err := c.Update(bson.M{"_id": id}, bson.M{"$set": bson.M{"name": name}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//a refused create is never written
+	Post(t, h, "/create/admin_test.T14/", url.Values{"Name": {"refuse"}})
+	expect("a refused create", "BeforeSave")
+	if count() != 0 {
+		t.Fatal("Refused create was saved")
+	}
+
+	Post(t, h, "/create/admin_test.T14/", url.Values{"Name": {"foo"}})
+	expect("create", "BeforeSave", "AfterSave")
+
+	var x T14
+	if err := c.Find(nil).One(&x); err != nil {
+		t.Fatal(err)
+	}
+	id := x.ID.Hex()
+
+	Post(t, h, "/update/admin_test.T14/"+id, url.Values{"Name": {"refuse"}})
+	expect("a refused update", "BeforeSave")
+	if err := c.Find(nil).One(&x); err != nil || x.Name != "foo" {
+		t.Fatalf("Refused update was saved: %+v %v", x, err)
+	}
+
+	Post(t, h, "/update/admin_test.T14/"+id, url.Values{"Name": {"bar"}})
+	expect("update", "BeforeSave", "AfterSave")
+
+	//the first version is the created one
+	Post(t, h, "/history/admin_test.T14/"+id, url.Values{"revert": {"1"}})
+	expect("revert", "BeforeSave", "AfterSave")
+	if err := c.Find(nil).One(&x); err != nil || x.Name != "foo" {
+		t.Fatalf("Revert was not saved: %+v %v", x, err)
+	}
+
+	//a refused delete leaves the document
+	rename(x.ID, "refuse")
+	Post(t, h, "/delete/admin_test.T14/"+id, url.Values{"_sure": {"yes"}})
+	expect("a refused delete", "BeforeDelete")
+	if count() != 1 {
+		t.Fatal("Refused delete removed the document")
+	}
+
+	rename(x.ID, "foo")
+	Post(t, h, "/list/admin_test.T14/", url.Values{
+		"_action":   {bulkDelete},
+		"_selected": {id},
+		"_sure":     {"yes"},
+	})
+	expect("bulk delete", "BeforeDelete", "AfterDelete")
+	if count() != 0 {
+		t.Fatal("Bulk delete left the document")
+	}
+}
+
+func TestInlineHooksCalled(t *testing.T) {
+	h := &Admin{
+		Session:  session,
+		Renderer: &TestRenderer{},
+	}
+	h.Register(T6{}, "admin_test.T6", &Options{
+		Inlines: []Inline{{Collection: "admin_test.T14", Field: "Name"}},
+	})
+	h.Register(T14{}, "admin_test.T14", nil)
+	req, _ := http.NewRequest("POST", "/update/admin_test.T6/4f07c34779bf562daff8640c", nil)
+
+	children := [][]*inlineChild{{
+		{prefix: "_inline0.0.", object: &T14{Name: "ok"}},
+		{prefix: "_inline0.1.", object: &T14{Name: "refuse"}},
+		{prefix: "_inline0.2.", object: &T14{Name: "refuse"}, delete: true},
+	}}
+
+	hookCalls = nil
+	errs := h.beforeSaveInlines(req, "admin_test.T6", children)
+	if expected := []string{"BeforeSave", "BeforeSave", "BeforeDelete"}; !reflect.DeepEqual(hookCalls, expected) {
+		t.Fatalf("Expected %v. Got %v", expected, hookCalls)
+	}
+	if len(errs) != 2 || errs["_inline0.1.Name"] == nil || errs["_inline0.2._id"] == nil {
+		t.Fatalf("Expected the refusals keyed by the children. Got %v", errs)
+	}
+
+	//the refusals are shown with the children
+	if children[0][1].errors["Name"] == nil {
+		t.Errorf("Refused save not shown on the child: %v", children[0][1].errors)
+	}
+	if _, ok := children[0][2].err.(HookError); !ok || children[0][2].delete {
+		t.Errorf("Refused delete not shown on the child: %+v", children[0][2])
+	}
+}
@@ -0,0 +1,283 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/zeebo/admin/forms"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Locale is a language the admin can be presented in. Tag is the language tag
+//matched against the Accept-Language header, like "de" or "ja". Messages maps
+//the english strings of the built in templates, field names and validation
+//messages to their translations, and anything missing from it is shown as is.
+//Numbers in lists are written with the Thousands and Decimal separators, and
+//times with the DateFormat layout. The zero Locale is the untranslated admin.
+type Locale struct {
+	Tag        string            `json:"tag"`
+	Messages   map[string]string `json:"messages"`
+	Thousands  string            `json:"thousands"`
+	Decimal    string            `json:"decimal"`
+	DateFormat string            `json:"date_format"`
+}
+
+//LoadLocale reads a Locale from a JSON file with the fields of the Locale, for
+//example
+//
+//	{
+//		"thousands": ".",
+//		"decimal": ",",
+//		"date_format": "02.01.2006 15:04",
+//		"messages": {"Save": "Speichern", "Name": "Name"}
+//	}
+//
+//If the file has no tag, the name of the file without its extension is used.
+func LoadLocale(path string) (Locale, error) {
+	var l Locale
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return l, err
+	}
+	if err := json.Unmarshal(data, &l); err != nil {
+		return l, fmt.Errorf("%s: %s", path, err)
+	}
+	if l.Tag == "" {
+		l.Tag = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return l, nil
+}
+
+//LoadLocales reads every .json file in the directory with LoadLocale, sorted by
+//file name.
+func LoadLocales(dir string) ([]Locale, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	locales := make([]Locale, 0, len(files))
+	for _, file := range files {
+		l, err := LoadLocale(file)
+		if err != nil {
+			return nil, err
+		}
+		locales = append(locales, l)
+	}
+	return locales, nil
+}
+
+//T returns the translation of the message. If there are any arguments, the
+//translation is used as a format string for them, so templates can write
+//{{$.Locale.T "Edit %s" .Collection}}.
+func (l Locale) T(msg string, args ...interface{}) string {
+	if t, ok := l.Messages[msg]; ok {
+		msg = t
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
+
+//Message returns the translated message of an error, or of a string or any
+//other value from a LoadingErrors or ValidationErrors map. It returns the empty
+//string for nil.
+func (l Locale) Message(v interface{}) string {
+	if err := l.fieldError(v); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+//fieldError turns a value from a LoadingErrors or ValidationErrors map into an
+//error with its message translated to the locale, if there is a translation.
+func (l Locale) fieldError(v interface{}) error {
+	err := fieldError(v)
+	if err == nil {
+		return nil
+	}
+	if t, ok := l.Messages[err.Error()]; ok {
+		return errors.New(t)
+	}
+	return err
+}
+
+//items returns the choices with their labels translated.
+func (l Locale) items(items []forms.Item) []forms.Item {
+	if items == nil {
+		return nil
+	}
+	out := make([]forms.Item, len(items))
+	for i, item := range items {
+		out[i] = forms.Item{Label: l.T(item.Label), Value: item.Value}
+	}
+	return out
+}
+
+//Number formats an integer or floating point number with the separators of
+//the locale. Anything else is formatted with fmt.Sprint.
+func (l Locale) Number(v interface{}) string {
+	s := fmt.Sprint(v)
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+	default:
+		return s
+	}
+
+	//leave exponents and infinities alone
+	if strings.ContainsAny(s, "eEnN") {
+		return s
+	}
+
+	sign, whole, frac := "", s, ""
+	if strings.HasPrefix(whole, "-") {
+		sign, whole = "-", whole[1:]
+	}
+	if i := strings.Index(whole, "."); i >= 0 {
+		whole, frac = whole[:i], whole[i+1:]
+	}
+
+	if l.Thousands != "" {
+		var groups []string
+		for len(whole) > 3 {
+			groups = append([]string{whole[len(whole)-3:]}, groups...)
+			whole = whole[:len(whole)-3]
+		}
+		whole = strings.Join(append([]string{whole}, groups...), l.Thousands)
+	}
+
+	if frac == "" {
+		return sign + whole
+	}
+	decimal := l.Decimal
+	if decimal == "" {
+		decimal = "."
+	}
+	return sign + whole + decimal + frac
+}
+
+//Date formats the time with the DateFormat of the locale, or the layout of the
+//built in templates if it has none.
+func (l Locale) Date(t time.Time) string {
+	if l.DateFormat == "" {
+		return t.Format("2006-01-02 15:04:05")
+	}
+	return t.Format(l.DateFormat)
+}
+
+//format is like formatValue, but writes numbers and times the way the locale
+//does.
+func (l Locale) format(val reflect.Value) string {
+	v, err := indirect(val)
+	if err != nil || !v.IsValid() || !v.CanInterface() {
+		return formatValue(val)
+	}
+
+	if t, ok := v.Interface().(time.Time); ok && l.DateFormat != "" {
+		return l.Date(t)
+	}
+	if _, ok := codecFor(v.Type()); ok {
+		return formatValue(v)
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return l.Number(v.Interface())
+	}
+	return formatValue(v)
+}
+
+//checkLocales panics if any locale has no tag or shares it with another.
+func (a *Admin) checkLocales() {
+	seen := map[string]bool{}
+	for _, l := range a.Locales {
+		tag := strings.ToLower(l.Tag)
+		if tag == "" {
+			panic("Locale without a Tag")
+		}
+		if seen[tag] {
+			panic(fmt.Sprintf("Duplicate locale %s", l.Tag))
+		}
+		seen[tag] = true
+	}
+}
+
+//findLocale returns the locale with the tag, or the locale for the language of
+//the tag if there is no exact match, so that "de-AT" finds "de".
+func (a *Admin) findLocale(tag string) (Locale, bool) {
+	tag = strings.ToLower(tag)
+	for _, candidate := range []string{tag, strings.SplitN(tag, "-", 2)[0]} {
+		for _, l := range a.Locales {
+			if strings.ToLower(l.Tag) == candidate {
+				return l, true
+			}
+		}
+	}
+	return Locale{}, false
+}
+
+//acceptLanguages returns the language tags in the Accept-Language header of the
+//request, most preferred first.
+func acceptLanguages(req *http.Request) []string {
+	type lang struct {
+		tag string
+		q   float64
+	}
+
+	var langs []lang
+	for _, part := range strings.Split(req.Header.Get("Accept-Language"), ",") {
+		fields := strings.Split(part, ";")
+		l := lang{tag: strings.TrimSpace(fields[0]), q: 1}
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					l.q = q
+				}
+			}
+		}
+		if l.tag != "" && l.tag != "*" && l.q > 0 {
+			langs = append(langs, l)
+		}
+	}
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].q > langs[j].q })
+
+	tags := make([]string, len(langs))
+	for i, l := range langs {
+		tags[i] = l.tag
+	}
+	return tags
+}
+
+//localeFor returns the locale the request is presented in: the one the logged
+//in user prefers, the best match for the Accept-Language header, or the first
+//of the Locales. Without Locales it is the zero Locale.
+func (a *Admin) localeFor(req *http.Request) Locale {
+	if len(a.Locales) == 0 {
+		return Locale{}
+	}
+	if auth, ex := a.auth_cache[req]; ex && auth.Locale != "" {
+		if l, ok := a.findLocale(auth.Locale); ok {
+			return l
+		}
+	}
+	for _, tag := range acceptLanguages(req) {
+		if l, ok := a.findLocale(tag); ok {
+			return l
+		}
+	}
+	return a.Locales[0]
+}
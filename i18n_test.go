@@ -0,0 +1,211 @@
+package admin
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var german = Locale{
+	Tag: "de",
+	Messages: map[string]string{
+		"Save":        "Speichern",
+		"Edit %s":     "%s bearbeiten",
+		"Name":        "Bezeichnung",
+		"bad bool":    "ungültiger Wahrheitswert",
+		"Collections": "Sammlungen",
+	},
+	Thousands:  ".",
+	Decimal:    ",",
+	DateFormat: "02.01.2006 15:04",
+}
+
+func TestLocaleT(t *testing.T) {
+	cases := []struct {
+		loc      Locale
+		msg      string
+		args     []interface{}
+		expected string
+	}{
+		{Locale{}, "Save", nil, "Save"},
+		{german, "Save", nil, "Speichern"},
+		{german, "Missing", nil, "Missing"},
+		{german, "Edit %s", []interface{}{"db.coll"}, "db.coll bearbeiten"},
+		{Locale{}, "Edit %s", []interface{}{"db.coll"}, "Edit db.coll"},
+	}
+	for _, c := range cases {
+		if got := c.loc.T(c.msg, c.args...); got != c.expected {
+			t.Errorf("%q: Expected %q. Got %q", c.msg, c.expected, got)
+		}
+	}
+
+	if got := german.Message(errors.New("bad bool")); got != "ungültiger Wahrheitswert" {
+		t.Errorf("Unexpected message %q", got)
+	}
+	if got := german.Message(nil); got != "" {
+		t.Errorf("Unexpected message for nil %q", got)
+	}
+}
+
+func TestLocaleNumber(t *testing.T) {
+	cases := []struct {
+		loc      Locale
+		v        interface{}
+		expected string
+	}{
+		{Locale{}, 1234567, "1234567"},
+		{Locale{}, 1234.5, "1234.5"},
+		{german, 1234567, "1.234.567"},
+		{german, -1234.5, "-1.234,5"},
+		{german, uint8(12), "12"},
+		{german, 123, "123"},
+		{german, 1e21, "1e+21"},
+		{german, "1234", "1234"},
+	}
+	for _, c := range cases {
+		if got := c.loc.Number(c.v); got != c.expected {
+			t.Errorf("%v: Expected %q. Got %q", c.v, c.expected, got)
+		}
+	}
+}
+
+func TestLocaleFormat(t *testing.T) {
+	when := time.Date(2012, 3, 4, 5, 6, 7, 0, time.UTC)
+	n := 12345
+
+	cases := []struct {
+		loc      Locale
+		v        interface{}
+		expected string
+	}{
+		{german, when, "04.03.2012 05:06"},
+		{german, &n, "12.345"},
+		{german, "text", "text"},
+		{Locale{}, n, "12345"},
+		{Locale{}, when, formatValue(reflect.ValueOf(when))},
+	}
+	for _, c := range cases {
+		if got := c.loc.format(reflect.ValueOf(c.v)); got != c.expected {
+			t.Errorf("%v: Expected %q. Got %q", c.v, c.expected, got)
+		}
+	}
+}
+
+func TestLocaleFor(t *testing.T) {
+	japanese := Locale{Tag: "ja"}
+	a := &Admin{Locales: []Locale{{Tag: "en"}, german, japanese}}
+
+	cases := map[string]string{
+		"":                            "en",
+		"ja":                          "ja",
+		"de-AT, en;q=0.5":             "de",
+		"fr, ja;q=0.8, de;q=0.9":      "de",
+		"fr, *;q=0.1":                 "en",
+		"de;q=0, ja;q=0.1":            "ja",
+		"pt-BR, pt;q=0.9, ja-JP;q=.3": "ja",
+	}
+	for accept, expected := range cases {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Language", accept)
+		if got := a.localeFor(req).Tag; got != expected {
+			t.Errorf("%q: Expected %q. Got %q", accept, expected, got)
+		}
+	}
+
+	//the locale of the user wins over the header
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Language", "de")
+	a.auth_cache = map[*http.Request]AuthSession{req: {Username: "bob", Locale: "ja"}}
+	if got := a.localeFor(req).Tag; got != "ja" {
+		t.Errorf("Expected the users locale. Got %q", got)
+	}
+
+	if got := (&Admin{}).localeFor(req); !reflect.DeepEqual(got, Locale{}) {
+		t.Errorf("Expected the zero locale without Locales. Got %v", got)
+	}
+}
+
+func TestCheckLocales(t *testing.T) {
+	cases := [][]Locale{
+		{{Tag: ""}},
+		{{Tag: "de"}, {Tag: "DE"}},
+	}
+	for _, locales := range cases {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected a panic for %v", locales)
+				}
+			}()
+			(&Admin{Locales: locales}).checkLocales()
+		}()
+	}
+}
+
+func TestLoadLocales(t *testing.T) {
+	dir, err := ioutil.TempDir("", "admin_locales")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"de.json": `{"thousands": ".", "decimal": ",", "messages": {"Save": "Speichern"}}`,
+		"jp.json": `{"tag": "ja", "messages": {"Save": "保存"}}`,
+		"notes":   `not a catalog`,
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	locales, err := LoadLocales(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locales) != 2 {
+		t.Fatalf("Expected 2 locales. Got %v", locales)
+	}
+	if l := locales[0]; l.Tag != "de" || l.Decimal != "," || l.T("Save") != "Speichern" {
+		t.Errorf("Unexpected locale: %+v", l)
+	}
+	if l := locales[1]; l.Tag != "ja" || l.T("Save") != "保存" {
+		t.Errorf("Unexpected locale: %+v", l)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "bad.json"), []byte(`{`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadLocales(dir); err == nil || !strings.Contains(err.Error(), "bad.json") {
+		t.Errorf("Expected an error naming the file. Got %v", err)
+	}
+}
+
+func TestGenerateFormLocale(t *testing.T) {
+	type named struct {
+		Name string
+		Ok   bool
+	}
+
+	ctx := TemplateContext{
+		Values: map[string]interface{}{"Name": "hello"},
+		Errors: map[string]interface{}{"Ok": errors.New("bad bool")},
+		Locale: german,
+	}
+	out, err := GenerateForm(named{}, ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"Bezeichnung", "ungültiger Wahrheitswert", `name="Name" value="hello"`} {
+		if !strings.Contains(out, s) {
+			t.Errorf("Expected %q in %s", s, out)
+		}
+	}
+}
@@ -0,0 +1,656 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"launchpad.net/mgo/bson"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//Styles for rendering the forms of an Inline.
+const (
+	InlineStacked = "stacked"
+	InlineTabular = "tabular"
+)
+
+//Inline configures editing the documents of another collection that reference
+//an object on the object's update and create pages. The children are loaded
+//and validated along with the parent, checking their references and storing
+//their uploads the same way, and every child written is audited and versioned.
+//Deleted children go to the trash if their collection uses soft deletes, and
+//trashed children are not shown. Existing children are checked for conflicting
+//updates like the parent, and a child that can't be edited along with the
+//parent is shown with an error instead. Children call the BeforeSaver and
+//BeforeDeleter hooks before anything is written, and their refusals block the
+//parent from being saved, and the AfterSaver and AfterDeleter hooks once they
+//are written. The children are saved one at a time after the parent, so the
+//save is not atomic: if writing a child fails, the parent and the children
+//before it stay saved and the error is shown.
+type Inline struct {
+	//Collection is the registered database/collection of the children.
+	Collection string
+
+	//Field is the dot separated path to the reference field in the children
+	//that points at the parent. If empty, the only reference field in the
+	//children pointing at the parent's collection is used.
+	Field string
+
+	//Style is InlineStacked or InlineTabular, and defaults to InlineStacked.
+	Style string
+
+	//Extra is the number of blank forms rendered for adding new children.
+	Extra int
+}
+
+//InlineSet is the set of forms for the children in one Inline, rendered inside
+//the parent's form.
+type InlineSet struct {
+	Collection string
+	Style      string
+	Forms      []InlineForm
+}
+
+//InlineForm is the form for a single child in an InlineSet. The names of the
+//fields in the form are prefixed with Prefix so that they are submitted along
+//with the parent. ID is the id of the child, and is empty for new children.
+//Error is why the submitted child couldn't be edited or saved, if it wasn't. A
+//renderer can offer deleting the child by adding a checkbox named Prefix +
+//"_delete" with the value "true".
+type InlineForm struct {
+	Form
+	Prefix string
+	ID     string
+	Object interface{}
+	Error  error
+}
+
+//ExecuteText returns the output of the Form along with the hidden input
+//carrying the id of the child, preceded by the Error if there is one.
+func (f InlineForm) ExecuteText() string {
+	var msg string
+	if f.Error != nil {
+		msg = fmt.Sprintf(`<p class="error">%s</p>`, html.EscapeString(f.context.Locale.Message(f.Error)))
+	}
+	return msg + fmt.Sprintf(`<input type="hidden" name="%s_id" value="%s">`,
+		html.EscapeString(f.Prefix), html.EscapeString(f.ID)) + f.Form.ExecuteText()
+}
+
+//errInlineConflict is the error for a child saved by someone else since its
+//form was rendered.
+var errInlineConflict = errors.New("Someone else saved this since you started editing. Submitting again overwrites it.")
+
+//inlineChild is a child document for an Inline, either loaded from the
+//database or from a submitted form. before and files have the values and files
+//of an existing child as they were in the database, match finds it only while
+//it stays that way, err is why it can't be edited or saved, and saved is set
+//once the child is written.
+type inlineChild struct {
+	prefix string
+	id     string
+	etag   string
+	match  bson.M
+	stored map[string]interface{}
+	before map[string]interface{}
+	files  map[string]File
+	object Formable
+	errors map[string]interface{}
+	err    error
+	delete bool
+	saved  bool
+}
+
+//inlinePrefix returns the prefix of the form keys for the nth child of the ith
+//inline.
+func inlinePrefix(i, n int) string {
+	return fmt.Sprintf("_inline%d.%d.", i, n)
+}
+
+//fieldType returns the type of the field at the dot separated path in the
+//struct type.
+func fieldType(typ reflect.Type, path string) (reflect.Type, bool) {
+	for _, name := range strings.Split(path, ".") {
+		field, ok := indirectType(typ).FieldByName(name)
+		if !ok {
+			return nil, false
+		}
+		typ = field.Type
+	}
+	return typ, true
+}
+
+//fieldQuery returns the value to query the field at the dot separated path in
+//the collection with for the formatted value, using the type of the field.
+func (a *Admin) fieldQuery(coll, path, data string) (interface{}, error) {
+	typ, ok := fieldType(a.types[coll].Type, path)
+	if !ok {
+		return nil, fmt.Errorf("No field %s in %s", path, coll)
+	}
+
+	val := reflect.New(typ).Elem()
+	if err := loadInto(val, data); err != nil {
+		return nil, err
+	}
+	return val.Interface(), nil
+}
+
+//checkInlines ensures every inline points at a registered collection, and
+//finds the reference field for inlines that did not specify one.
+func (a *Admin) checkInlines() {
+	for coll, info := range a.types {
+		for i, inline := range info.Options.Inlines {
+			if !a.hasType(inline.Collection) {
+				panic(fmt.Sprintf("%s has an inline for %s which is not registered", coll, inline.Collection))
+			}
+
+			if inline.Field == "" {
+				var fields []string
+				for _, ref := range a.referencesTo(coll) {
+					if ref.Collection == inline.Collection {
+						fields = append(fields, ref.Field)
+					}
+				}
+				if len(fields) != 1 {
+					panic(fmt.Sprintf("%s has an inline for %s without a Field, and %d references to choose from", coll, inline.Collection, len(fields)))
+				}
+				info.Options.Inlines[i].Field = fields[0]
+			}
+
+			if _, ok := fieldType(a.types[inline.Collection].Type, info.Options.Inlines[i].Field); !ok {
+				panic(fmt.Sprintf("%s has an inline for %s with an unknown Field %s", coll, inline.Collection, inline.Field))
+			}
+			if info.Options.Inlines[i].Style == "" {
+				info.Options.Inlines[i].Style = InlineStacked
+			}
+		}
+	}
+}
+
+//inlineIndexes returns the sorted child indexes submitted in the form for the
+//ith inline.
+func inlineIndexes(form url.Values, i int) []int {
+	prefix := fmt.Sprintf("_inline%d.", i)
+
+	seen, indexes := map[int]bool{}, []int{}
+	for key := range form {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		rest := key[len(prefix):]
+		dot := strings.Index(rest, ".")
+		if dot < 0 {
+			continue
+		}
+		n, err := strconv.Atoi(rest[:dot])
+		if err != nil || n < 0 || seen[n] {
+			continue
+		}
+		seen[n] = true
+		indexes = append(indexes, n)
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+//strayInlineKeys returns the sorted keys in the form that look like they belong
+//to an inline child, but don't name one of the count inlines and a child index.
+func strayInlineKeys(form url.Values, count int) []string {
+	keys := []string{}
+	for key := range form {
+		if !strings.HasPrefix(key, "_inline") {
+			continue
+		}
+		parts := strings.SplitN(key[len("_inline"):], ".", 3)
+		if len(parts) == 3 {
+			i, ierr := strconv.Atoi(parts[0])
+			n, nerr := strconv.Atoi(parts[1])
+			if ierr == nil && nerr == nil && i >= 0 && i < count && n >= 0 &&
+				strings.HasPrefix(key, inlinePrefix(i, n)) {
+				continue
+			}
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+//subValues returns the values in the form with the prefix, with the prefix
+//stripped off the keys.
+func subValues(form url.Values, prefix string) url.Values {
+	sub := url.Values{}
+	for key, vals := range form {
+		if strings.HasPrefix(key, prefix) {
+			sub[key[len(prefix):]] = vals
+		}
+	}
+	return sub
+}
+
+//isBlank returns if every value in the child's form is empty. Unchecked
+//checkboxes send "false", so that counts as empty too.
+func isBlank(sub url.Values) bool {
+	for key, vals := range sub {
+		if key == "_id" {
+			continue
+		}
+		for _, v := range vals {
+			if v != "" && v != "false" {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+//mergeErrors returns the errors from both maps in one map.
+func mergeErrors(a, b map[string]interface{}) map[string]interface{} {
+	if len(b) == 0 {
+		return a
+	}
+	merged := map[string]interface{}{}
+	for key, err := range a {
+		merged[key] = err
+	}
+	for key, err := range b {
+		merged[key] = err
+	}
+	return merged
+}
+
+//loadInlines loads and validates every child submitted in the request for the
+//inlines of the collection. parent is the formatted id of the parent, and is
+//empty when the parent is being created. Any errors are returned keyed by the
+//prefixed field names so that they block the parent from being saved, and so
+//are children that can't be edited, keyed by their prefixed _id, and keys that
+//don't belong to any child. The handler must pass the children to
+//cleanupInlines once they are saved or not.
+func (a *Admin) loadInlines(req *http.Request, coll, parent string) (children [][]*inlineChild, errors map[string]interface{}, err error) {
+	inlines := a.types[coll].Options.Inlines
+	loaded, errors := make([][]*inlineChild, len(inlines)), map[string]interface{}{}
+
+	//drop anything uploaded if the rest can't be loaded
+	defer func() {
+		if err != nil {
+			a.cleanupInlines(loaded)
+		}
+	}()
+
+	//keys that don't belong to any child come from a broken form
+	for _, key := range strayInlineKeys(req.Form, len(inlines)) {
+		errors[key] = fmt.Errorf("Unknown inline field %s", key)
+	}
+
+	for i, inline := range inlines {
+		for _, n := range inlineIndexes(req.Form, i) {
+			prefix := inlinePrefix(i, n)
+			sub := subValues(req.Form, prefix)
+
+			child := &inlineChild{
+				prefix: prefix,
+				id:     sub.Get("_id"),
+				object: a.newType(inline.Collection),
+				delete: sub.Get("_delete") == "true",
+			}
+
+			//skip extra forms that were left blank
+			if child.id == "" && (child.delete || isBlank(sub)) {
+				continue
+			}
+
+			//grab the existing child, showing it again if it can't be edited
+			if child.id != "" {
+				if child.err, err = a.findChild(inline, parent, sub.Get(etagKey), child); err != nil {
+					return nil, nil, err
+				}
+				if child.err != nil {
+					errors[prefix+"_id"] = child.err
+					child.delete = false
+				}
+			}
+			loaded[i] = append(loaded[i], child)
+
+			if child.delete {
+				continue
+			}
+
+			//load and validate the child just like the parent
+			child.errors, err = a.loadObject(req, sub, inline.Collection, prefix, child.object)
+			if err != nil {
+				return nil, nil, err
+			}
+			for key, err := range child.errors {
+				errors[prefix+key] = err
+			}
+		}
+	}
+
+	return loaded, errors, nil
+}
+
+//findChild loads the existing child of the parent with the formatted id from
+//the database, remembering how it looked to save it later. Submitting a child
+//that is malformed, missing, belongs to another parent or was saved since its
+//form was rendered with the sent etag is returned as invalid, and err is only
+//for failing to read it.
+func (a *Admin) findChild(inline Inline, parent, sent string, child *inlineChild) (invalid, err error) {
+	if parent == "" {
+		return fmt.Errorf("Can't edit existing children of a new object"), nil
+	}
+	q, err := a.idQuery(inline.Collection, child.id)
+	if err != nil {
+		return err, nil
+	}
+	query := a.liveQuery(inline.Collection, bson.M{"_id": q})
+	if err := a.collFor(inline.Collection).Find(query).One(child.object); err != nil {
+		if err.Error() == "Document not found" {
+			return fmt.Errorf("%s %s does not exist", inline.Collection, child.id), nil
+		}
+		return nil, err
+	}
+	if referenceId(child.object, inline.Field) != parent {
+		//don't show them another parent's child
+		child.object = a.newType(inline.Collection)
+		return fmt.Errorf("%s %s is not a child of %s", inline.Collection, child.id, parent), nil
+	}
+
+	if child.etag, err = a.etag(inline.Collection, child.object); err != nil {
+		return nil, err
+	}
+	if child.match, err = a.matchQuery(inline.Collection, q, child.object); err != nil {
+		return nil, err
+	}
+	if child.stored, err = a.storedValues(inline.Collection, child.object); err != nil {
+		return nil, err
+	}
+	if child.before, err = formValues(child.object); err != nil {
+		return nil, err
+	}
+	child.files = filesIn(child.object)
+
+	if a.staleEtag(inline.Collection, sent, child.etag) {
+		return errInlineConflict, nil
+	}
+	return nil, nil
+}
+
+//cleanupInlines drops the files uploaded for the children that end up unused,
+//like cleanupFiles does for the parent.
+func (a *Admin) cleanupInlines(children [][]*inlineChild) {
+	for _, set := range children {
+		for _, child := range set {
+			if !child.delete {
+				a.cleanupFiles(child.files, filesIn(child.object), child.saved)
+			}
+		}
+	}
+}
+
+//beforeSaveInlines runs the BeforeSave hook on the children being saved and the
+//BeforeDelete hook on the children being deleted. Any errors are set on the
+//children and returned keyed like loadInlines does, and a child that refuses to
+//be deleted is kept so that it is shown with the HookError.
+func (a *Admin) beforeSaveInlines(req *http.Request, coll string, children [][]*inlineChild) map[string]interface{} {
+	errors := map[string]interface{}{}
+	for i, inline := range a.types[coll].Options.Inlines {
+		for _, child := range children[i] {
+			if child.delete {
+				if err := a.beforeDelete(req, inline.Collection, child.object); err != nil {
+					child.err, child.delete = err, false
+					errors[child.prefix+"_id"] = err
+				}
+				continue
+			}
+
+			errs := a.beforeSave(req, inline.Collection, child.object)
+			child.errors = mergeErrors(child.errors, errs)
+			for key, err := range errs {
+				errors[child.prefix+key] = err
+			}
+		}
+	}
+	return errors
+}
+
+//saveInlines saves the loaded children of the parent with the formatted id in
+//order, stopping at the first error. Every write is audited and versioned. A
+//child that someone else saved in the meantime is left unsaved with its err
+//set, and saved reports if every child was written.
+func (a *Admin) saveInlines(req *http.Request, coll, parent string, children [][]*inlineChild) (saved bool, err error) {
+	saved = true
+	for i, inline := range a.types[coll].Options.Inlines {
+		for _, child := range children[i] {
+			if err := a.saveInline(req, inline, parent, child); err != nil {
+				return false, err
+			}
+			if child.err != nil {
+				saved = false
+			}
+		}
+	}
+	return saved, nil
+}
+
+//saveInline deletes, updates or inserts the child of the parent with the
+//formatted id, pointing its reference field at the parent.
+func (a *Admin) saveInline(req *http.Request, inline Inline, parent string, child *inlineChild) error {
+	c := a.collFor(inline.Collection)
+
+	var q interface{}
+	if child.id != "" {
+		var err error
+		if q, err = a.idQuery(inline.Collection, child.id); err != nil {
+			return err
+		}
+	}
+
+	if child.delete {
+		if err := a.removeObject(inline.Collection, q, child.object); err != nil {
+			return err
+		}
+		a.afterDelete(req, inline.Collection, child.object)
+		a.audit(req, ActionDelete, inline.Collection, child.id, child.before, nil)
+		return nil
+	}
+
+	field, ok := fieldByPath(reflect.ValueOf(child.object), inline.Field)
+	if !ok {
+		return fmt.Errorf("Can't set %s on %s", inline.Field, inline.Collection)
+	}
+	if err := loadInto(field, parent); err != nil {
+		return err
+	}
+
+	action := ActionUpdate
+	if child.id != "" {
+		if err := a.bumpVersion(inline.Collection, child.object, child.etag); err != nil {
+			return err
+		}
+		if err := a.partialUpdate(inline.Collection, child.match, child.stored, child.object); err != nil {
+			if err.Error() != "Document not found" {
+				return err
+			}
+			return a.inlineConflict(inline.Collection, q, child)
+		}
+	} else {
+		//give it an id up front so the write can be recorded
+		action = ActionCreate
+		a.assignId(child.object)
+		if err := c.Insert(child.object); err != nil {
+			return err
+		}
+		child.id = Reverser{a}.idFor(child.object)
+	}
+	child.saved = true
+	a.afterSave(req, inline.Collection, child.object)
+
+	//the form is shown again if another child fails
+	var err error
+	if child.etag, err = a.etag(inline.Collection, child.object); err != nil {
+		return err
+	}
+
+	after, _ := formValues(child.object)
+	a.audit(req, action, inline.Collection, child.id, child.before, after)
+	a.snapshot(req, inline.Collection, child.id, after)
+	return nil
+}
+
+//inlineConflict marks the child with the id as saved by someone else, taking
+//the etag of their version so that submitting it again overwrites them.
+func (a *Admin) inlineConflict(coll string, id interface{}, child *inlineChild) error {
+	current := a.newType(coll)
+	if err := a.collFor(coll).Find(a.liveQuery(coll, bson.M{"_id": id})).One(current); err != nil {
+		if err.Error() != "Document not found" {
+			return err
+		}
+		child.err = fmt.Errorf("%s %s does not exist", coll, child.id)
+		return nil
+	}
+
+	var err error
+	if child.etag, err = a.etag(coll, current); err != nil {
+		return err
+	}
+	child.err = errInlineConflict
+	return nil
+}
+
+//assignId sets the id of the object to a new ObjectId if it is an empty
+//bson.ObjectId.
+func (a *Admin) assignId(t Formable) {
+	val, err := indirect(reflect.ValueOf(t))
+	if err != nil {
+		return
+	}
+	idx, ok := a.object_id[val.Type()]
+	if !ok {
+		return
+	}
+	if id, ok := val.Field(idx).Interface().(bson.ObjectId); ok && id == "" {
+		val.Field(idx).Set(reflect.ValueOf(bson.NewObjectId()))
+	}
+}
+
+//queryInlines returns the children of the parent with the formatted id from the
+//database for every inline of the collection.
+func (a *Admin) queryInlines(coll, parent string) ([][]*inlineChild, error) {
+	inlines := a.types[coll].Options.Inlines
+	children := make([][]*inlineChild, len(inlines))
+
+	for i, inline := range inlines {
+		q, err := a.fieldQuery(inline.Collection, inline.Field, parent)
+		if err != nil {
+			return nil, err
+		}
+
+		key := bsonPath(a.types[inline.Collection].Type, inline.Field)
+		query := a.liveQuery(inline.Collection, bson.M{key: q})
+		iter := a.collFor(inline.Collection).Find(query).Iter()
+		for n := 0; ; n++ {
+			t := a.newType(inline.Collection)
+			if !iter.Next(t) {
+				break
+			}
+			etag, err := a.etag(inline.Collection, t)
+			if err != nil {
+				return nil, err
+			}
+			children[i] = append(children[i], &inlineChild{
+				prefix: inlinePrefix(i, n),
+				id:     Reverser{a}.idFor(t),
+				etag:   etag,
+				object: t,
+			})
+		}
+		if err := iter.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return children, nil
+}
+
+//inlineSets turns the children of every inline of the collection into
+//InlineSets for rendering, adding the Extra blank forms to each. Deleted
+//children are not rendered.
+func (a *Admin) inlineSets(req *http.Request, coll string, children [][]*inlineChild) ([]InlineSet, error) {
+	inlines := a.types[coll].Options.Inlines
+	if len(inlines) == 0 {
+		return nil, nil
+	}
+
+	sets := make([]InlineSet, len(inlines))
+	for i, inline := range inlines {
+		sets[i] = InlineSet{
+			Collection: inline.Collection,
+			Style:      inline.Style,
+		}
+
+		//the reference to the parent is set for them
+		omit := a.omitted(inline.Collection)
+		omit[inline.Field] = true
+
+		n := 0
+		for _, child := range children[i] {
+			if child.delete {
+				continue
+			}
+			ctx, err := a.generateContext(req, inline.Collection, child.object, child.errors)
+			if err != nil {
+				return nil, err
+			}
+			ctx.Prefix, ctx.Omit = child.prefix, omit
+
+			//existing children carry their etag like the parent
+			var hidden url.Values
+			if child.id != "" {
+				hidden = url.Values{child.prefix + etagKey: {child.etag}}
+			}
+			sets[i].Forms = append(sets[i].Forms, InlineForm{
+				Form:   Form{object: child.object, context: ctx, logger: a.logger, hidden: hidden},
+				Prefix: child.prefix,
+				ID:     child.id,
+				Object: child.object,
+				Error:  child.err,
+			})
+			if n <= indexOf(child.prefix) {
+				n = indexOf(child.prefix) + 1
+			}
+		}
+
+		//add the blank forms after every existing index
+		for j := 0; j < inline.Extra; j, n = j+1, n+1 {
+			t := a.newType(inline.Collection)
+			values, err := CreateEmptyValues(t)
+			if err != nil {
+				return nil, err
+			}
+			sets[i].Forms = append(sets[i].Forms, InlineForm{
+				Form: Form{object: t, logger: a.logger, context: TemplateContext{
+					Values:  values,
+					Lookups: a.lookups(inline.Collection),
+					Prefix:  inlinePrefix(i, n),
+					Omit:    omit,
+					Locale:  a.localeFor(req),
+				}},
+				Prefix: inlinePrefix(i, n),
+				Object: t,
+			})
+		}
+	}
+
+	return sets, nil
+}
+
+//indexOf returns the child index out of an inline prefix.
+func indexOf(prefix string) int {
+	parts := strings.Split(prefix, ".")
+	n, _ := strconv.Atoi(parts[1])
+	return n
+}
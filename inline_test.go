@@ -0,0 +1,253 @@
+package admin
+
+import (
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInlineIndexes(t *testing.T) {
+	form := url.Values{
+		"Name":             {"parent"},
+		"_inline0.2.Name":  {"c"},
+		"_inline0.0.Name":  {"a"},
+		"_inline0.0._id":   {""},
+		"_inline0.x.Name":  {"bad"},
+		"_inline0.10.Name": {"d"},
+		"_inline1.1.Name":  {"other"},
+		"_inline0.3":       {"bad"},
+		"_inline0.-1.Name": {"bad"},
+	}
+
+	if got := inlineIndexes(form, 0); !reflect.DeepEqual(got, []int{0, 2, 10}) {
+		t.Fatalf("Expected [0 2 10]. Got %v", got)
+	}
+
+	sub := subValues(form, inlinePrefix(0, 0))
+	if !reflect.DeepEqual(sub, url.Values{"Name": {"a"}, "_id": {""}}) {
+		t.Fatalf("Unexpected sub values: %v", sub)
+	}
+}
+
+func TestIsBlank(t *testing.T) {
+	table := []struct {
+		values   url.Values
+		expected bool
+	}{
+		{url.Values{}, true},
+		{url.Values{"Name": {""}, "Active": {"false"}}, true},
+		{url.Values{"_id": {"4f07c34779bf562daff8640c"}}, true},
+		{url.Values{"Name": {"foo"}}, false},
+		{url.Values{"Active": {"true", "false"}}, false},
+	}
+
+	for _, c := range table {
+		if got := isBlank(c.values); got != c.expected {
+			t.Errorf("%v: Expected %v. Got %v", c.values, c.expected, got)
+		}
+	}
+}
+
+func TestCheckInlines(t *testing.T) {
+	h := &Admin{
+		Renderer: &TestRenderer{},
+	}
+	opts := &Options{
+		Inlines: []Inline{{Collection: "admin_test.T11", Extra: 1}},
+	}
+	h.Register(T6{}, "admin_test.T6", opts)
+	h.Register(T11{}, "admin_test.T11", nil)
+	h.checkInlines()
+
+	inline := h.types["admin_test.T6"].Options.Inlines[0]
+	if inline.Field != "Customer" || inline.Style != InlineStacked {
+		t.Fatalf("Inline not resolved: %+v", inline)
+	}
+	if opts.Inlines[0].Field != "" {
+		t.Fatal("Resolving the inline modified the passed in options")
+	}
+}
+
+func TestCheckInlinesAmbiguous(t *testing.T) {
+	h := &Admin{
+		Renderer: &TestRenderer{},
+	}
+	h.Register(T6{}, "admin_test.T6", &Options{
+		Inlines: []Inline{{Collection: "admin_test.T11"}},
+	})
+	h.Register(T11{}, "admin_test.T11", &Options{
+		References: map[string]string{"Shipping.Address": "admin_test.T6"},
+	})
+
+	defer func() {
+		if err := recover(); err == nil {
+			t.Fatal("No panic with two references to choose from")
+		}
+	}()
+	h.checkInlines()
+}
+
+func TestGenerateFormPrefix(t *testing.T) {
+	ctx := NewTemplateContext()
+	ctx.Prefix = "_inline0.1."
+	ctx.Omit = map[string]bool{"Level": true}
+	ctx.Errors["Status"] = "bad status"
+
+	html, err := GenerateForm(T10{}, ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{`name="_inline0.1.Status"`, `name="_inline0.1.Inner.Color"`, "bad status"} {
+		if !strings.Contains(html, name) {
+			t.Errorf("Expected %s in the form:\n%s", name, html)
+		}
+	}
+	if strings.Contains(html, "Level") {
+		t.Errorf("Omitted field rendered:\n%s", html)
+	}
+}
+
+func TestInlineFormExecuteText(t *testing.T) {
+	f := InlineForm{
+		Form:   Form{object: T10{}},
+		Prefix: "_inline0.1.",
+		ID:     `4f07c34779bf562daff8640c`,
+	}
+
+	expected := `<input type="hidden" name="_inline0.1._id" value="4f07c34779bf562daff8640c">`
+	if got := f.ExecuteText(); got != expected {
+		t.Fatalf("Expected %s. Got %s", expected, got)
+	}
+}
+
+func TestLoadInlinesFiles(t *testing.T) {
+	store := tempStore(t)
+	defer os.RemoveAll(store.Dir)
+
+	h := &Admin{
+		Files:  store,
+		logger: log.New(ioutil.Discard, "", 0),
+	}
+	h.Register(T6{}, "admin_test.T6", &Options{
+		Inlines: []Inline{{Collection: "admin_test.T9", Field: "Name"}},
+	})
+	h.Register(T9{}, "admin_test.T9", &Options{
+		Uploads: map[string]UploadLimits{
+			"Nested.Doc": {MaxSize: 4},
+		},
+	})
+
+	req := multipartRequest(t, map[string]string{
+		"_inline0.0.Name": "a",
+		"_inline0.1.Name": "b",
+	}, map[string][]byte{
+		"_inline0.0.Avatar":     png,
+		"_inline0.1.Nested.Doc": png,
+	})
+	if err := req.ParseMultipartForm(maxMemory); err != nil {
+		t.Fatal(err)
+	}
+
+	children, errs, err := h.loadInlines(req, "admin_test.T6", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 1 || errs["_inline0.1.Nested.Doc"] == nil {
+		t.Fatalf("Expected an error for the oversized file of the second child. Got %v", errs)
+	}
+
+	first := children[0][0].object.(*T9)
+	if first.Avatar.ID == "" || first.Avatar.Name != "_inline0.0.Avatar.png" {
+		t.Fatalf("File not loaded into the child: %+v", first)
+	}
+
+	//nothing was saved, so the upload is dropped
+	h.cleanupInlines(children)
+	if _, _, err := store.Get(first.Avatar.ID); err != ErrBlobNotFound {
+		t.Fatalf("Expected the upload to be deleted. Got %v", err)
+	}
+}
+
+func TestStrayInlineKeys(t *testing.T) {
+	form := url.Values{
+		"_inline0.0.Name":  {"a"},
+		"_inline1.2._id":   {""},
+		"_inline0.x.Name":  {"bad"},
+		"_inline2.0.Name":  {"no such inline"},
+		"_inline0.01.Name": {"not canonical"},
+		"_inline0.-1.Name": {"bad"},
+		"_inline0.3":       {"bad"},
+		"Name":             {"parent"},
+	}
+
+	expected := []string{"_inline0.-1.Name", "_inline0.01.Name", "_inline0.3", "_inline0.x.Name", "_inline2.0.Name"}
+	if got := strayInlineKeys(form, 2); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected %v. Got %v", expected, got)
+	}
+}
+
+func TestLoadInlinesInvalidChild(t *testing.T) {
+	h := &Admin{logger: log.New(ioutil.Discard, "", 0)}
+	h.Register(T6{}, "admin_test.T6", &Options{
+		Inlines: []Inline{{Collection: "admin_test.T11"}},
+	})
+	h.Register(T11{}, "admin_test.T11", nil)
+	h.checkInlines()
+
+	cases := []struct {
+		parent string
+		values map[string]string
+		key    string
+	}{
+		{"", map[string]string{"_inline0.0._id": "4f07c34779bf562daff8640c", "_inline0.0.Shipping.Address": ""}, "_inline0.0._id"},
+		{"4f07c34779bf562daff8640d", map[string]string{"_inline0.0._id": "not an id", "_inline0.0._delete": "true"}, "_inline0.0._id"},
+		{"4f07c34779bf562daff8640d", map[string]string{"_inline0.x.Shipping.Address": ""}, "_inline0.x.Shipping.Address"},
+	}
+
+	for _, c := range cases {
+		req := multipartRequest(t, c.values, nil)
+		if err := req.ParseMultipartForm(maxMemory); err != nil {
+			t.Fatal(err)
+		}
+
+		children, errs, err := h.loadInlines(req, "admin_test.T6", c.parent)
+		if err != nil {
+			t.Fatalf("%v: Expected a form error. Got %v", c.values, err)
+		}
+		if len(errs) != 1 || errs[c.key] == nil {
+			t.Fatalf("%v: Expected an error for %s. Got %v", c.values, c.key, errs)
+		}
+		if c.key != "_inline0.0._id" {
+			continue
+		}
+
+		//the child is shown again with the error, even if it was deleted
+		if len(children[0]) != 1 {
+			t.Fatalf("%v: Expected the child to be kept. Got %v", c.values, children[0])
+		}
+		if child := children[0][0]; child.err == nil || child.delete {
+			t.Fatalf("%v: Expected the child to be shown with its error. Got %+v", c.values, child)
+		}
+	}
+}
+
+func TestInlineFormExecuteTextError(t *testing.T) {
+	f := InlineForm{
+		Form:   Form{object: T10{}, hidden: url.Values{"_inline0.1._etag": {"3"}}},
+		Prefix: "_inline0.1.",
+		ID:     `4f07c34779bf562daff8640c`,
+		Error:  errInlineConflict,
+	}
+
+	got := f.ExecuteText()
+	for _, part := range []string{`<p class="error">Someone else saved this`, `name="_inline0.1._etag" value="3"`} {
+		if !strings.Contains(got, part) {
+			t.Errorf("Expected %s in %s", part, got)
+		}
+	}
+}
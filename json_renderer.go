@@ -0,0 +1,461 @@
+package admin
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+//JSONRenderer is a Renderer that serializes every context as JSON, for single
+//page frontends driving the admin. Forms are serialized as their values and
+//errors, and failed attempts get a status code describing the failure: 422 for
+//invalid forms, 409 for conflicting updates and 401 for failed logins.
+type JSONRenderer struct{}
+
+//writeJSON writes the value as JSON with the status code.
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		panic(err)
+	}
+}
+
+//attemptStatus returns the status code for a page that may have attempted to
+//make a change.
+func attemptStatus(attempted, success bool) int {
+	if attempted && !success {
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusOK
+}
+
+//errorString returns the message of the error, or the empty string if it is nil.
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+//jsonErrors formats the errors of a form, which may be errors or strings, as
+//strings translated to the locale keyed by the dot separated path to the field.
+func jsonErrors(l Locale, errors map[string]interface{}) map[string]string {
+	if len(errors) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(errors))
+	for key, err := range errors {
+		out[key] = l.Message(err)
+	}
+	return out
+}
+
+//jsonForm returns the values and errors of the form.
+func jsonForm(f Form) d {
+	return d{
+		"values": f.context.Values,
+		"errors": jsonErrors(f.context.Locale, f.context.Errors),
+	}
+}
+
+//jsonPagination returns the current page and number of pages.
+func jsonPagination(p Pagination) d {
+	return d{
+		"page":  p.CurrentPage,
+		"pages": p.Pages,
+	}
+}
+
+//jsonId returns the formatted id of the object, or the empty string if the
+//admin doesn't know its type.
+func jsonId(r Reverser, obj interface{}) string {
+	if r.admin == nil || obj == nil {
+		return ""
+	}
+	if _, ok := r.admin.object_id[indirectType(reflect.TypeOf(obj))]; !ok {
+		return ""
+	}
+	return r.idFor(obj)
+}
+
+//jsonBase returns what the BaseContext has to say: the managed collections, the
+//logged in user and their locale, and the title and banner of the theme.
+func jsonBase(c BaseContext) d {
+	var user string
+	if c.Auth != nil {
+		user = c.Auth.Username
+	}
+	return d{
+		"managed": c.Managed,
+		"groups":  c.Groups,
+		"user":    user,
+		"locale":  c.Locale.Tag,
+		"title":   c.Theme.Title,
+		"banner":  c.Theme.Banner,
+	}
+}
+
+//with returns the base values with the values added.
+func (b d) with(values d) d {
+	for key, val := range values {
+		b[key] = val
+	}
+	return b
+}
+
+//NotFound implements the Renderer interface.
+func (JSONRenderer) NotFound(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, http.StatusNotFound, d{"error": "Not found"})
+}
+
+//InternalError implements the Renderer interface.
+func (JSONRenderer) InternalError(w http.ResponseWriter, req *http.Request, err error) {
+	writeJSON(w, http.StatusInternalServerError, d{"error": errorString(err)})
+}
+
+//Detail implements the Renderer interface.
+func (JSONRenderer) Detail(w http.ResponseWriter, req *http.Request, c DetailContext) {
+	actions := make([]string, len(c.Actions))
+	for i, action := range c.Actions {
+		actions[i] = action.Name
+	}
+	writeJSON(w, http.StatusOK, jsonBase(c.BaseContext).with(d{
+		"collection": c.Collection,
+		"id":         jsonId(c.Reverser, c.Object),
+		"object":     c.Object,
+		"form":       jsonForm(c.Form),
+		"files":      c.Files,
+		"links":      c.Links,
+		"related":    c.Related,
+		"actions":    actions,
+	}))
+}
+
+//Delete implements the Renderer interface.
+func (JSONRenderer) Delete(w http.ResponseWriter, req *http.Request, c DeleteContext) {
+	code := attemptStatus(c.Attempted, c.Success)
+	if _, ok := c.Error.(HookError); c.Error != nil && !ok {
+		code = http.StatusInternalServerError
+	}
+	writeJSON(w, code, jsonBase(c.BaseContext).with(d{
+		"collection": c.Collection,
+		"id":         jsonId(c.Reverser, c.Object),
+		"object":     c.Object,
+		"attempted":  c.Attempted,
+		"success":    c.Success,
+		"error":      errorString(c.Error),
+		"form":       jsonForm(c.Form),
+		"dependents": c.Dependents,
+	}))
+}
+
+//Index implements the Renderer interface.
+func (j JSONRenderer) Index(w http.ResponseWriter, req *http.Request, c BaseContext) {
+	j.Dashboard(w, req, IndexContext{BaseContext: c})
+}
+
+//Dashboard implements the DashboardRenderer interface.
+func (JSONRenderer) Dashboard(w http.ResponseWriter, req *http.Request, c IndexContext) {
+	widgets := make([]d, len(c.Widgets))
+	for i, widget := range c.Widgets {
+		widgets[i] = d{
+			"template": widget.Template,
+			"data":     widget.Data,
+			"error":    errorString(widget.Error),
+			"updated":  widget.Updated,
+		}
+	}
+	writeJSON(w, http.StatusOK, jsonBase(c.BaseContext).with(d{
+		"counts":  c.Counts,
+		"recent":  c.Recent,
+		"widgets": widgets,
+	}))
+}
+
+//List implements the Renderer interface.
+func (JSONRenderer) List(w http.ResponseWriter, req *http.Request, c ListContext) {
+	actions := make([]d, len(c.Actions))
+	for i, action := range c.Actions {
+		actions[i] = d{"name": action.Name, "label": action.Label}
+	}
+	writeJSON(w, http.StatusOK, jsonBase(c.BaseContext).with(d{
+		"collection": c.Collection,
+		"columns":    c.Columns,
+		"values":     c.Values,
+		"files":      c.Files,
+		"links":      c.Links,
+		"ids":        c.IDs,
+		"actions":    actions,
+		"objects":    c.Objects,
+		"pagination": jsonPagination(c.Pagination),
+	}))
+}
+
+//jsonInlines returns the forms of the inline sets, with the etag existing
+//children must be submitted with.
+func jsonInlines(sets []InlineSet) []d {
+	out := make([]d, len(sets))
+	for i, set := range sets {
+		forms := make([]d, len(set.Forms))
+		for j, form := range set.Forms {
+			forms[j] = jsonForm(form.Form).with(d{
+				"prefix": form.Prefix,
+				"id":     form.ID,
+				"etag":   form.hidden.Get(form.Prefix + etagKey),
+				"error":  form.context.Locale.Message(form.Error),
+			})
+		}
+		out[i] = d{
+			"collection": set.Collection,
+			"style":      set.Style,
+			"forms":      forms,
+		}
+	}
+	return out
+}
+
+//Update implements the Renderer interface.
+func (JSONRenderer) Update(w http.ResponseWriter, req *http.Request, c UpdateContext) {
+	code := attemptStatus(c.Attempted, c.Success)
+	if c.Conflict {
+		code = http.StatusConflict
+	}
+	writeJSON(w, code, jsonBase(c.BaseContext).with(d{
+		"collection": c.Collection,
+		"id":         jsonId(c.Reverser, c.Object),
+		"object":     c.Object,
+		"attempted":  c.Attempted,
+		"success":    c.Success,
+		"error":      errorString(c.Error),
+		"form":       jsonForm(c.Form),
+		"inlines":    jsonInlines(c.Inlines),
+		"conflict":   c.Conflict,
+		"current":    c.Current,
+		"etag":       c.Etag,
+	}))
+}
+
+//Create implements the Renderer interface.
+func (JSONRenderer) Create(w http.ResponseWriter, req *http.Request, c CreateContext) {
+	code := attemptStatus(c.Attempted, c.Success)
+	if c.Success {
+		code = http.StatusCreated
+	}
+	writeJSON(w, code, jsonBase(c.BaseContext).with(d{
+		"collection": c.Collection,
+		"attempted":  c.Attempted,
+		"success":    c.Success,
+		"error":      errorString(c.Error),
+		"form":       jsonForm(c.Form),
+		"inlines":    jsonInlines(c.Inlines),
+	}))
+}
+
+//Authorize implements the Renderer interface.
+func (JSONRenderer) Authorize(w http.ResponseWriter, req *http.Request, c AuthorizeContext) {
+	code := http.StatusOK
+	if c.Attempted && !c.Success {
+		code = http.StatusUnauthorized
+	}
+	writeJSON(w, code, jsonBase(c.BaseContext).with(d{
+		"attempted": c.Attempted,
+		"success":   c.Success,
+		"error":     c.Error,
+	}))
+}
+
+//LoggedOut implements the Renderer interface.
+func (JSONRenderer) LoggedOut(w http.ResponseWriter, req *http.Request, c BaseContext) {
+	writeJSON(w, http.StatusOK, jsonBase(c))
+}
+
+//Audit implements the AuditRenderer interface.
+func (JSONRenderer) Audit(w http.ResponseWriter, req *http.Request, c AuditContext) {
+	writeJSON(w, http.StatusOK, jsonBase(c.BaseContext).with(d{
+		"filter":     c.Filter,
+		"entries":    c.Entries,
+		"pagination": jsonPagination(c.Pagination),
+	}))
+}
+
+//History implements the HistoryRenderer interface.
+func (JSONRenderer) History(w http.ResponseWriter, req *http.Request, c HistoryContext) {
+	code := attemptStatus(c.Attempted, c.Success)
+	if c.Conflict {
+		code = http.StatusConflict
+	}
+	writeJSON(w, code, jsonBase(c.BaseContext).with(d{
+		"collection": c.Collection,
+		"id":         jsonId(c.Reverser, c.Object),
+		"object":     c.Object,
+		"versions":   c.Versions,
+		"from":       c.From,
+		"to":         c.To,
+		"diff":       c.Diff,
+		"attempted":  c.Attempted,
+		"success":    c.Success,
+		"conflict":   c.Conflict,
+		"errors":     jsonErrors(c.Locale, c.Errors),
+	}))
+}
+
+//Trash implements the TrashRenderer interface.
+func (JSONRenderer) Trash(w http.ResponseWriter, req *http.Request, c TrashContext) {
+	items := make([]d, len(c.Items))
+	for i, item := range c.Items {
+		items[i] = d{
+			"id":      jsonId(c.Reverser, item.Object),
+			"object":  item.Object,
+			"deleted": item.Deleted,
+		}
+	}
+	writeJSON(w, attemptStatus(c.Attempted, c.Success), jsonBase(c.BaseContext).with(d{
+		"collection": c.Collection,
+		"items":      items,
+		"action":     c.Action,
+		"attempted":  c.Attempted,
+		"success":    c.Success,
+		"error":      errorString(c.Error),
+	}))
+}
+
+//Bulk implements the BulkRenderer interface.
+func (JSONRenderer) Bulk(w http.ResponseWriter, req *http.Request, c BulkContext) {
+	code := attemptStatus(c.Attempted, c.Success)
+	if len(c.IDs) == 0 {
+		code = http.StatusBadRequest
+	}
+	writeJSON(w, code, jsonBase(c.BaseContext).with(d{
+		"collection": c.Collection,
+		"action":     d{"name": c.Action.Name, "label": c.Action.Label},
+		"ids":        c.IDs,
+		"all":        c.All,
+		"attempted":  c.Attempted,
+		"success":    c.Success,
+		"error":      errorString(c.Error),
+	}))
+}
+
+//Action implements the ActionRenderer interface.
+func (JSONRenderer) Action(w http.ResponseWriter, req *http.Request, c ObjectActionContext) {
+	var form d
+	if c.Action.Form != nil {
+		form = jsonForm(c.Form)
+	}
+	writeJSON(w, attemptStatus(c.Attempted, c.Success), jsonBase(c.BaseContext).with(d{
+		"collection": c.Collection,
+		"id":         jsonId(c.Reverser, c.Object),
+		"object":     c.Object,
+		"action":     d{"name": c.Action.Name, "label": c.Action.Label},
+		"form":       form,
+		"attempted":  c.Attempted,
+		"success":    c.Success,
+		"message":    c.Message,
+		"error":      errorString(c.Error),
+	}))
+}
+
+//Webhooks implements the WebhookRenderer interface.
+func (JSONRenderer) Webhooks(w http.ResponseWriter, req *http.Request, c WebhookContext) {
+	writeJSON(w, http.StatusOK, jsonBase(c.BaseContext).with(d{
+		"deliveries": c.Deliveries,
+		"pagination": jsonPagination(c.Pagination),
+	}))
+}
+
+//NegotiatingRenderer is a Renderer that passes every call to the JSON renderer
+//if the request prefers application/json in its Accept header, and to the HTML
+//renderer otherwise. The pages with optional interfaces are presented by the
+//renderer it picks if it implements them. If the Admin has no Renderer, it uses
+//one with the default html renderer and a JSONRenderer.
+type NegotiatingRenderer struct {
+	HTML Renderer
+	JSON Renderer
+}
+
+//wantsJSON returns if the Accept header of the request prefers JSON to HTML.
+func wantsJSON(req *http.Request) bool {
+	var html, json float64
+	for _, part := range strings.Split(req.Header.Get("Accept"), ",") {
+		typ, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+
+		switch typ {
+		case "application/json":
+			json = q
+		case "text/html":
+			html = q
+		}
+	}
+	return json > html
+}
+
+//pick returns the renderer for the request.
+func (n NegotiatingRenderer) pick(req *http.Request) Renderer {
+	if wantsJSON(req) {
+		return n.JSON
+	}
+	return n.HTML
+}
+
+//NotFound implements the Renderer interface.
+func (n NegotiatingRenderer) NotFound(w http.ResponseWriter, req *http.Request) {
+	n.pick(req).NotFound(w, req)
+}
+
+//InternalError implements the Renderer interface.
+func (n NegotiatingRenderer) InternalError(w http.ResponseWriter, req *http.Request, err error) {
+	n.pick(req).InternalError(w, req, err)
+}
+
+//Detail implements the Renderer interface.
+func (n NegotiatingRenderer) Detail(w http.ResponseWriter, req *http.Request, c DetailContext) {
+	n.pick(req).Detail(w, req, c)
+}
+
+//Delete implements the Renderer interface.
+func (n NegotiatingRenderer) Delete(w http.ResponseWriter, req *http.Request, c DeleteContext) {
+	n.pick(req).Delete(w, req, c)
+}
+
+//Index implements the Renderer interface.
+func (n NegotiatingRenderer) Index(w http.ResponseWriter, req *http.Request, c BaseContext) {
+	n.pick(req).Index(w, req, c)
+}
+
+//List implements the Renderer interface.
+func (n NegotiatingRenderer) List(w http.ResponseWriter, req *http.Request, c ListContext) {
+	n.pick(req).List(w, req, c)
+}
+
+//Update implements the Renderer interface.
+func (n NegotiatingRenderer) Update(w http.ResponseWriter, req *http.Request, c UpdateContext) {
+	n.pick(req).Update(w, req, c)
+}
+
+//Create implements the Renderer interface.
+func (n NegotiatingRenderer) Create(w http.ResponseWriter, req *http.Request, c CreateContext) {
+	n.pick(req).Create(w, req, c)
+}
+
+//Authorize implements the Renderer interface.
+func (n NegotiatingRenderer) Authorize(w http.ResponseWriter, req *http.Request, c AuthorizeContext) {
+	n.pick(req).Authorize(w, req, c)
+}
+
+//LoggedOut implements the Renderer interface.
+func (n NegotiatingRenderer) LoggedOut(w http.ResponseWriter, req *http.Request, c BaseContext) {
+	n.pick(req).LoggedOut(w, req, c)
+}
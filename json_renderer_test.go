@@ -0,0 +1,210 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestWantsJSON(t *testing.T) {
+	cases := map[string]bool{
+		"":                                    false,
+		"text/html":                           false,
+		"application/json":                    true,
+		"text/html, application/json":         false,
+		"application/json, text/html;q=0.9":   true,
+		"text/html;q=0.5, application/json":   true,
+		"application/json;q=0.1, text/html":   false,
+		"*/*":                                 false,
+		"application/json;q=bad, text/plain":  false,
+		"text/html, application/xhtml+xml, *": false,
+	}
+	for accept, expected := range cases {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", accept)
+		if got := wantsJSON(req); got != expected {
+			t.Errorf("%q: Expected %v. Got %v", accept, expected, got)
+		}
+	}
+}
+
+func TestJSONRendererStatus(t *testing.T) {
+	form := Form{context: NewTemplateContext()}
+	form.context.Values["X"] = "foo"
+	form.context.Errors["X"] = "bad value"
+
+	cases := []struct {
+		render func(Renderer, http.ResponseWriter, *http.Request)
+		code   int
+	}{
+		{func(r Renderer, w http.ResponseWriter, req *http.Request) {
+			r.NotFound(w, req)
+		}, http.StatusNotFound},
+		{func(r Renderer, w http.ResponseWriter, req *http.Request) {
+			r.InternalError(w, req, errors.New("broken"))
+		}, http.StatusInternalServerError},
+		{func(r Renderer, w http.ResponseWriter, req *http.Request) {
+			r.Update(w, req, UpdateContext{Form: form})
+		}, http.StatusOK},
+		{func(r Renderer, w http.ResponseWriter, req *http.Request) {
+			r.Update(w, req, UpdateContext{Attempted: true, Form: form})
+		}, http.StatusUnprocessableEntity},
+		{func(r Renderer, w http.ResponseWriter, req *http.Request) {
+			r.Update(w, req, UpdateContext{Attempted: true, Conflict: true, Form: form})
+		}, http.StatusConflict},
+		{func(r Renderer, w http.ResponseWriter, req *http.Request) {
+			r.Create(w, req, CreateContext{Attempted: true, Success: true, Form: form})
+		}, http.StatusCreated},
+		{func(r Renderer, w http.ResponseWriter, req *http.Request) {
+			r.(HistoryRenderer).History(w, req, HistoryContext{Attempted: true, Conflict: true})
+		}, http.StatusConflict},
+		{func(r Renderer, w http.ResponseWriter, req *http.Request) {
+			r.Authorize(w, req, AuthorizeContext{Attempted: true, Error: "nope"})
+		}, http.StatusUnauthorized},
+		{func(r Renderer, w http.ResponseWriter, req *http.Request) {
+			r.Delete(w, req, DeleteContext{Attempted: true, Error: errors.New("broken"), Form: form})
+		}, http.StatusInternalServerError},
+		{func(r Renderer, w http.ResponseWriter, req *http.Request) {
+			r.Delete(w, req, DeleteContext{Attempted: true, Error: HookError{}, Form: form})
+		}, http.StatusUnprocessableEntity},
+	}
+
+	for i, c := range cases {
+		w, req := httptest.NewRecorder(), &http.Request{Header: http.Header{}}
+		c.render(JSONRenderer{}, w, req)
+		if w.Code != c.code {
+			t.Errorf("%d: Expected %d. Got %d", i, c.code, w.Code)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+			t.Errorf("%d: Unexpected content type %q", i, ct)
+		}
+	}
+}
+
+func TestJSONRendererForm(t *testing.T) {
+	form := Form{context: NewTemplateContext()}
+	form.context.Values["X"] = "foo"
+	form.context.Errors["X"] = "bad value"
+
+	w, req := httptest.NewRecorder(), &http.Request{Header: http.Header{}}
+	JSONRenderer{}.Create(w, req, CreateContext{
+		BaseContext: BaseContext{Auth: &AuthSession{Username: "bob"}},
+		Collection:  "db.coll",
+		Attempted:   true,
+		Form:        form,
+	})
+
+	var got struct {
+		User       string
+		Collection string
+		Attempted  bool
+		Success    bool
+		Form       struct {
+			Values map[string]string
+			Errors map[string]string
+		}
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.User != "bob" || got.Collection != "db.coll" || !got.Attempted || got.Success {
+		t.Errorf("Unexpected payload: %+v", got)
+	}
+	if got.Form.Values["X"] != "foo" || got.Form.Errors["X"] != "bad value" {
+		t.Errorf("Unexpected form: %+v", got.Form)
+	}
+}
+
+func TestJSONRendererInlines(t *testing.T) {
+	form := Form{
+		context: NewTemplateContext(),
+		hidden:  url.Values{"_inline0.0._etag": {"3"}},
+	}
+
+	w, req := httptest.NewRecorder(), &http.Request{Header: http.Header{}}
+	JSONRenderer{}.Update(w, req, UpdateContext{
+		Attempted: true,
+		Form:      Form{context: NewTemplateContext()},
+		Inlines: []InlineSet{{Collection: "db.child", Forms: []InlineForm{{
+			Form:   form,
+			Prefix: "_inline0.0.",
+			ID:     "4f07c34779bf562daff8640c",
+			Error:  errInlineConflict,
+		}}}},
+	})
+
+	var got struct {
+		Inlines []struct {
+			Forms []struct {
+				Prefix string
+				ID     string
+				Etag   string
+				Error  string
+			}
+		}
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Inlines) != 1 || len(got.Inlines[0].Forms) != 1 {
+		t.Fatalf("Unexpected inlines: %+v", got.Inlines)
+	}
+	if f := got.Inlines[0].Forms[0]; f.Etag != "3" || f.Error != errInlineConflict.Error() {
+		t.Errorf("Unexpected inline form: %+v", f)
+	}
+}
+
+func TestNegotiatingRenderer(t *testing.T) {
+	tr := &TestRenderer{}
+	r := NegotiatingRenderer{HTML: tr, JSON: JSONRenderer{}}
+
+	w, req := httptest.NewRecorder(), &http.Request{Header: http.Header{}}
+	req.Header.Set("Accept", "application/json")
+	r.Index(w, req, BaseContext{})
+	if len(tr.Calls) != 0 {
+		t.Fatal("JSON request rendered as html")
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+		t.Fatalf("Unexpected content type %q", ct)
+	}
+
+	req.Header.Set("Accept", "text/html")
+	r.Index(httptest.NewRecorder(), req, BaseContext{})
+	if last := tr.Last(); last.Type != "Index" {
+		t.Fatalf("Expected an Index call. Got %v", last)
+	}
+}
+
+//plainRenderer only has the methods of the Renderer interface.
+type plainRenderer struct {
+	Renderer
+}
+
+func TestPageRenderer(t *testing.T) {
+	tr := &TestRenderer{}
+	req := &http.Request{Header: http.Header{}}
+
+	h := &Admin{Renderer: plainRenderer{tr}}
+	if _, ok := h.pageRenderer(req).(AuditRenderer); ok {
+		t.Fatal("Renderer without the optional interface used for the audit page")
+	}
+
+	h.Renderer = NegotiatingRenderer{HTML: tr, JSON: JSONRenderer{}}
+	h.renderAudit(httptest.NewRecorder(), req, AuditContext{})
+	if last := tr.Last(); last.Type != "Audit" {
+		t.Fatalf("Expected an Audit call. Got %v", last)
+	}
+
+	w := httptest.NewRecorder()
+	req.Header.Set("Accept", "application/json")
+	h.renderAudit(w, req, AuditContext{})
+	if len(tr.Calls) != 1 || w.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+		t.Fatal("JSON request not rendered by the JSON renderer")
+	}
+	if _, ok := h.pageRenderer(req).(DashboardRenderer); !ok {
+		t.Fatal("Expected the JSON renderer to present the dashboard")
+	}
+}
@@ -19,9 +19,10 @@ func grabInt(v url.Values, key string, def int) int {
 	return int(n)
 }
 
-//listParse takes a collection and some query values and generates an iterator
-//for the objects that should be returned on that page
-func listParse(c *mgo.Collection, v url.Values) (*mgo.Iter, int, int) {
+//listParse takes a collection, the query selecting the objects in the list and
+//some query values and generates an iterator for the objects that should be
+//returned on that page
+func listParse(c *mgo.Collection, q interface{}, v url.Values) (*mgo.Iter, int, int) {
 	//parse out sorting
 	sort := map[string]int{}
 	for key, _ := range v {
@@ -37,7 +38,7 @@ func listParse(c *mgo.Collection, v url.Values) (*mgo.Iter, int, int) {
 		}
 	}
 	//set up the query with the correct sort order
-	query := c.Find(nil).Sort(sort)
+	query := c.Find(q).Sort(sort)
 
 	//pagination
 	page, numpage := grabInt(v, "page", 0), grabInt(v, "numpage", 20)
@@ -0,0 +1,82 @@
+package admin
+
+import (
+	"launchpad.net/mgo/bson"
+	"reflect"
+	"strings"
+)
+
+//storedValues returns the value of every field the collection's type models,
+//keyed by the bson path it is stored under. Fields that aren't stored, because
+//they are behind a nil pointer or are empty with omitempty, are nil. The id
+//field and fields tagged with bson:"-" are left out.
+func (a *Admin) storedValues(coll string, t Formable) (map[string]interface{}, error) {
+	typ := a.types[coll].Type
+	val, err := indirect(reflect.ValueOf(t))
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]interface{}{}
+	walkFields(typ, "", func(name string, sf reflect.StructField) {
+		tags := strings.Split(sf.Tag.Get("bson"), ",")
+		if isIdField(sf) || tags[0] == "-" {
+			return
+		}
+
+		v := storedValue(val, name)
+		for _, tag := range tags[1:] {
+			if tag == "omitempty" && v != nil && reflect.DeepEqual(v, reflect.Zero(reflect.TypeOf(v)).Interface()) {
+				v = nil
+			}
+		}
+		values[bsonPath(typ, name)] = v
+	})
+	return values, nil
+}
+
+//updateQuery returns the update that changes a document stored with the before
+//values to the after values, as returned by storedValues, touching only the
+//fields that changed. Fields that are no longer stored are unset. It returns
+//nil if nothing changed.
+func updateQuery(before, after map[string]interface{}) bson.M {
+	set, unset := bson.M{}, bson.M{}
+	for key, val := range after {
+		if old, ok := before[key]; ok && reflect.DeepEqual(old, val) {
+			continue
+		}
+		if val == nil {
+			unset[key] = 1
+		} else {
+			set[key] = val
+		}
+	}
+
+	update := bson.M{}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	if len(update) == 0 {
+		return nil
+	}
+	return update
+}
+
+//partialUpdate saves the changes made to the object since it had the before
+//values, as returned by storedValues, to the document matching the query. Any
+//fields in the document the type does not model are left alone.
+func (a *Admin) partialUpdate(coll string, match interface{}, before map[string]interface{}, t Formable) error {
+	after, err := a.storedValues(coll, t)
+	if err != nil {
+		return err
+	}
+
+	update := updateQuery(before, after)
+	if update == nil {
+		return nil
+	}
+	return a.collFor(coll).Update(match, update)
+}
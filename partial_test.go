@@ -0,0 +1,46 @@
+package admin
+
+import (
+	"launchpad.net/mgo/bson"
+	"reflect"
+	"testing"
+)
+
+func TestStoredValues(t *testing.T) {
+	h := &Admin{}
+	h.Register(T11{}, "admin_test.T11", nil)
+
+	x := &T11{Customer: bson.ObjectIdHex("4f07c34779bf562daff8640c")}
+	expected := map[string]interface{}{
+		"cust":             x.Customer,
+		"shipping.address": bson.ObjectId(""),
+	}
+	if got, err := h.storedValues("admin_test.T11", x); err != nil || !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected %v. Got %v %v", expected, got, err)
+	}
+}
+
+func TestUpdateQuery(t *testing.T) {
+	before := map[string]interface{}{
+		"name":       "foo",
+		"rev":        1,
+		"inner.note": "note",
+	}
+	after := map[string]interface{}{
+		"name":       nil,
+		"rev":        2,
+		"inner.note": "note",
+	}
+
+	expected := bson.M{
+		"$set":   bson.M{"rev": 2},
+		"$unset": bson.M{"name": 1},
+	}
+	if got := updateQuery(before, after); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected %v. Got %v", expected, got)
+	}
+
+	if got := updateQuery(before, before); got != nil {
+		t.Fatalf("Expected no update without changes. Got %v", got)
+	}
+}
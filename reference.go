@@ -0,0 +1,380 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"launchpad.net/mgo/bson"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+//lookupLimit is the maximum number of results returned by the lookup handler.
+const lookupLimit = 20
+
+//relatedLimit is the maximum number of related objects shown on the detail page
+//for each reference.
+const relatedLimit = 5
+
+//walkFields calls fn with the dot separated path and struct field of every basic
+//field in the struct type, recursing into nested structs.
+func walkFields(typ reflect.Type, prefix string, fn func(string, reflect.StructField)) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name, ftyp := prefix+field.Name, indirectType(field.Type)
+		if isBasic(ftyp) {
+			fn(name, field)
+			continue
+		}
+		walkFields(ftyp, name+".", fn)
+	}
+}
+
+//fieldByPath returns the value at the dot separated path in the struct value. It
+//returns false if the path does not exist or passes through a nil pointer.
+func fieldByPath(val reflect.Value, path string) (reflect.Value, bool) {
+	for _, name := range strings.Split(path, ".") {
+		v, err := indirect(val)
+		if err != nil || v.Kind() != reflect.Struct {
+			return reflect.Value{}, false
+		}
+		if val = v.FieldByName(name); !val.IsValid() {
+			return reflect.Value{}, false
+		}
+	}
+	return val, true
+}
+
+//bsonPath turns the dot separated path of struct field names into the dot
+//separated path of the keys mgo stores them under: the name in the bson tag, or
+//the lowercased field name.
+func bsonPath(typ reflect.Type, path string) string {
+	var keys []string
+	for _, name := range strings.Split(path, ".") {
+		typ = indirectType(typ)
+		field, ok := typ.FieldByName(name)
+		if !ok {
+			return ""
+		}
+
+		key := strings.Split(field.Tag.Get("bson"), ",")[0]
+		if key == "" {
+			key = strings.ToLower(field.Name)
+		}
+		keys = append(keys, key)
+		typ = field.Type
+	}
+	return strings.Join(keys, ".")
+}
+
+//findReferences returns the map of dot separated field paths to the
+//database/collection they reference, from ref tags on the type and the
+//References in the options. For example
+//
+//	Customer bson.ObjectId `ref:"shop.customers"`
+func findReferences(typ reflect.Type, opt *Options) map[string]string {
+	refs := map[string]string{}
+	walkFields(typ, "", func(name string, field reflect.StructField) {
+		if ref := field.Tag.Get("ref"); ref != "" {
+			refs[name] = ref
+		}
+	})
+	for name, ref := range opt.References {
+		if bsonPath(typ, name) == "" {
+			panic(fmt.Sprintf("Can't find a field named %s on type %s for a reference", name, typ))
+		}
+		refs[name] = ref
+	}
+	return refs
+}
+
+//checkReferences ensures every reference points at a registered collection.
+func (a *Admin) checkReferences() {
+	for coll, info := range a.types {
+		for name, ref := range info.References {
+			if !a.hasType(ref) {
+				panic(fmt.Sprintf("%s.%s references %s which is not registered", coll, name, ref))
+			}
+		}
+	}
+}
+
+//referenceId returns the formatted id stored in the reference field at the path
+//in the object, or the empty string if there is none.
+func referenceId(obj interface{}, path string) string {
+	field, ok := fieldByPath(reflect.ValueOf(obj), path)
+	if !ok {
+		return ""
+	}
+	return formatValue(field)
+}
+
+//idQuery returns the value to query the _id of the collection with for the
+//formatted id, using the type of the collection's id field.
+func (a *Admin) idQuery(coll, id string) (interface{}, error) {
+	typ := a.types[coll].Type
+	field := typ.Field(a.object_id[typ])
+
+	val := reflect.New(field.Type).Elem()
+	if err := loadInto(val, id); err != nil {
+		return nil, err
+	}
+	return val.Interface(), nil
+}
+
+//loadReferences ensures that every reference in the object points at an
+//existing document, returning LoadingErrors for those that don't.
+func (a *Admin) loadReferences(coll string, t Formable) (LoadingErrors, error) {
+	errs := LoadingErrors{}
+	for name, ref := range a.types[coll].References {
+		id := referenceId(t, name)
+		if id == "" {
+			continue
+		}
+
+		q, err := a.idQuery(ref, id)
+		if err != nil {
+			errs[name] = err
+			continue
+		}
+
+		n, err := a.collFor(ref).Find(a.liveQuery(ref, bson.M{"_id": q})).Count()
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			errs[name] = fmt.Errorf("No %s with id %s", ref, id)
+		}
+	}
+	return errs, nil
+}
+
+//referenceLinks returns a map of the dot separated path of every reference in
+//the object to the url of the detail page of the document it references.
+func (a *Admin) referenceLinks(coll string, obj interface{}) map[string]string {
+	links, reverser := map[string]string{}, Reverser{a}
+	for name, ref := range a.types[coll].References {
+		if id := referenceId(obj, name); id != "" {
+			links[name] = reverser.Detail(ref, id)
+		}
+	}
+	return links
+}
+
+//lookups returns a map of the dot separated path of every reference in the
+//collection to the url of the lookup handler for the referenced collection.
+func (a *Admin) lookups(coll string) map[string]string {
+	urls, reverser := map[string]string{}, Reverser{a}
+	for name, ref := range a.types[coll].References {
+		urls[name] = reverser.Lookup(ref)
+	}
+	return urls
+}
+
+//Dependent describes documents in a collection that reference another document
+//through a field. It is used to warn about deleting referenced documents.
+type Dependent struct {
+	Collection string
+	Field      string
+	Count      int
+}
+
+//reference is a field in a collection that references another collection.
+type reference struct {
+	Collection string
+	Field      string
+}
+
+//referencesTo returns every reference field in the registered collections that
+//points at the collection, sorted by collection and field.
+func (a *Admin) referencesTo(coll string) []reference {
+	var refs []reference
+	for from, info := range a.types {
+		for name, ref := range info.References {
+			if ref == coll {
+				refs = append(refs, reference{from, name})
+			}
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].Collection != refs[j].Collection {
+			return refs[i].Collection < refs[j].Collection
+		}
+		return refs[i].Field < refs[j].Field
+	})
+	return refs
+}
+
+//referenceQuery is the query for the documents referencing a document through
+//a reference.
+type referenceQuery struct {
+	reference
+	query bson.M
+}
+
+//referenceQueries returns the queries for the documents referencing the
+//document with the formatted id in the collection through every reference to
+//it. The id is loaded into the type of each reference field, and references
+//whose field can't hold it are left out since nothing references it there.
+func (a *Admin) referenceQueries(coll, id string) []referenceQuery {
+	var queries []referenceQuery
+	for _, ref := range a.referencesTo(coll) {
+		q, err := a.fieldQuery(ref.Collection, ref.Field, id)
+		if err != nil {
+			continue
+		}
+
+		key := bsonPath(a.types[ref.Collection].Type, ref.Field)
+		query := a.liveQuery(ref.Collection, bson.M{key: q})
+		queries = append(queries, referenceQuery{ref, query})
+	}
+	return queries
+}
+
+//dependents counts the documents that reference the document with the
+//formatted id in the collection.
+func (a *Admin) dependents(coll, id string) ([]Dependent, error) {
+	var deps []Dependent
+	for _, ref := range a.referenceQueries(coll, id) {
+		n, err := a.collFor(ref.Collection).Find(ref.query).Count()
+		if err != nil {
+			return nil, err
+		}
+		if n > 0 {
+			deps = append(deps, Dependent{ref.Collection, ref.Field, n})
+		}
+	}
+	return deps, nil
+}
+
+//Related describes the documents in a collection that reference an object
+//through a field, for showing on the object's detail page. Count is the total
+//number of referencing documents, and Objects holds the first few of them so
+//they can be linked with Reverser.DetailObj.
+type Related struct {
+	Collection string
+	Field      string
+	Count      int
+	Objects    []interface{}
+}
+
+//related finds the documents in every collection that reference the document
+//with the formatted id in the collection. References without any documents are
+//left out.
+func (a *Admin) related(coll, id string) ([]Related, error) {
+	var rels []Related
+	for _, ref := range a.referenceQueries(coll, id) {
+		query := a.collFor(ref.Collection).Find(ref.query)
+		n, err := query.Count()
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			continue
+		}
+
+		rel := Related{Collection: ref.Collection, Field: ref.Field, Count: n}
+		iter := query.Limit(relatedLimit).Iter()
+		for {
+			t := a.newType(ref.Collection)
+			if !iter.Next(t) {
+				break
+			}
+			rel.Objects = append(rel.Objects, t)
+		}
+		if err := iter.Err(); err != nil {
+			return nil, err
+		}
+		rels = append(rels, rel)
+	}
+	return rels, nil
+}
+
+//LookupResult is the type serialized by the lookup handler for each matching
+//document. Label is the result of the String method if the object is a
+//fmt.Stringer, and the id otherwise.
+type LookupResult struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+	URL   string `json:"url"`
+}
+
+//searchQuery returns the query for documents in the collection matching the
+//search term. The term matches the id exactly, or any of the SearchFields in
+//the collection's Options case insensitively.
+func (a *Admin) searchQuery(coll, q string) bson.M {
+	if q == "" {
+		return nil
+	}
+
+	info := a.types[coll]
+	var or []bson.M
+	if id, err := a.idQuery(coll, q); err == nil {
+		or = append(or, bson.M{"_id": id})
+	}
+	for _, name := range info.Options.SearchFields {
+		or = append(or, bson.M{bsonPath(info.Type, name): bson.M{
+			"$regex":   regexp.QuoteMeta(q),
+			"$options": "i",
+		}})
+	}
+	//no way to match anything
+	if len(or) == 0 {
+		return bson.M{"_id": bson.M{"$exists": false}}
+	}
+	return bson.M{"$or": or}
+}
+
+//Serves a JSON list of documents in a collection matching the q parameter for
+//autocompleting reference fields
+func (a *Admin) lookup(w http.ResponseWriter, req *http.Request) {
+	coll, id := parseRequest(req.URL.Path)
+
+	//ensure we have a collection, but no id
+	if coll == "" || id != "" {
+		a.Renderer.NotFound(w, req)
+		return
+	}
+
+	//make sure we know about the requested collection
+	if !a.hasType(coll) {
+		a.Renderer.NotFound(w, req)
+		return
+	}
+
+	query := a.liveQuery(coll, a.searchQuery(coll, req.URL.Query().Get("q")))
+	iter := a.collFor(coll).Find(query).Limit(lookupLimit).Iter()
+
+	reverser := Reverser{a}
+	results := []LookupResult{}
+	for {
+		t := a.newType(coll)
+		if !iter.Next(t) {
+			break
+		}
+
+		result := LookupResult{ID: reverser.idFor(t)}
+		result.Label, result.URL = result.ID, reverser.Detail(coll, result.ID)
+		if s, ok := t.(fmt.Stringer); ok {
+			result.Label = s.String()
+		}
+		results = append(results, result)
+	}
+
+	//report any errors our iterator made
+	if err := iter.Err(); err != nil {
+		a.Renderer.InternalError(w, req, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(results); err != nil {
+		a.logger.Printf("Error sending lookup results: %s", err)
+	}
+}
@@ -0,0 +1,182 @@
+package admin
+
+import (
+	"launchpad.net/mgo/bson"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBsonPath(t *testing.T) {
+	typ := reflect.TypeOf(T11{})
+	table := []struct {
+		path     string
+		expected string
+	}{
+		{"ID", "_id"},
+		{"Customer", "cust"},
+		{"Shipping.Address", "shipping.address"},
+		{"Missing", ""},
+		{"Shipping.Missing", ""},
+	}
+
+	for _, c := range table {
+		if got := bsonPath(typ, c.path); got != c.expected {
+			t.Errorf("%s: Expected %q. Got %q", c.path, c.expected, got)
+		}
+	}
+}
+
+func TestFindReferences(t *testing.T) {
+	h := &Admin{
+		Renderer: &TestRenderer{},
+	}
+	h.Register(T6{}, "admin_test.T6", nil)
+	h.Register(T11{}, "admin_test.T11", &Options{
+		References: map[string]string{"Shipping.Address": "admin_test.T6"},
+	})
+
+	expected := map[string]string{
+		"Customer":         "admin_test.T6",
+		"Shipping.Address": "admin_test.T6",
+	}
+	if got := h.types["admin_test.T11"].References; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected %v. Got %v", expected, got)
+	}
+
+	refs := h.referencesTo("admin_test.T6")
+	if len(refs) != 2 || refs[0].Field != "Customer" || refs[1].Field != "Shipping.Address" {
+		t.Fatalf("Unexpected references to T6: %v", refs)
+	}
+}
+
+func TestFindReferencesMissingField(t *testing.T) {
+	h := &Admin{
+		Renderer: &TestRenderer{},
+	}
+
+	defer func() {
+		if err := recover(); err == nil {
+			t.Fatal("No panic when referencing a field that doesn't exist")
+		}
+	}()
+
+	h.Register(T11{}, "admin_test.T11", &Options{
+		References: map[string]string{"Nope": "admin_test.T6"},
+	})
+}
+
+func TestReferenceQueries(t *testing.T) {
+	h := &Admin{
+		Renderer: &TestRenderer{},
+	}
+	h.Register(T6{}, "admin_test.T6", nil)
+	h.Register(T11{}, "admin_test.T11", nil)
+	h.Register(T13{}, "admin_test.T13", nil)
+
+	id := "4f07c34779bf562daff8640c"
+	queries := h.referenceQueries("admin_test.T6", id)
+
+	expected := []referenceQuery{
+		{reference{"admin_test.T11", "Customer"}, bson.M{"cust": bson.ObjectIdHex(id)}},
+		{reference{"admin_test.T13", "Owner"}, bson.M{"owner": id}},
+	}
+	if !reflect.DeepEqual(queries, expected) {
+		t.Fatalf("Expected %v. Got %v", expected, queries)
+	}
+}
+
+func TestReferenceId(t *testing.T) {
+	x := T11{Customer: bson.ObjectIdHex("4f07c34779bf562daff8640c")}
+	if id := referenceId(x, "Customer"); id != "4f07c34779bf562daff8640c" {
+		t.Fatalf("Expected %q. Got %q", "4f07c34779bf562daff8640c", id)
+	}
+	if id := referenceId(&x, "Shipping.Address"); id != "" {
+		t.Fatalf("Expected an empty id. Got %q", id)
+	}
+}
+
+func TestGenerateFormAutocomplete(t *testing.T) {
+	ctx := NewTemplateContext()
+	ctx.Values["Customer"] = "4f07c34779bf562daff8640c"
+	ctx.Lookups["Customer"] = "/lookup/admin_test.T6"
+
+	out, err := GenerateForm(T11{}, ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := `class="autocomplete" id="Customer" name="Customer" value="4f07c34779bf562daff8640c" data-lookup="/lookup/admin_test.T6"`
+	if !strings.Contains(out, s) {
+		t.Fatalf("Expected %q in output.\nGot: %s", s, out)
+	}
+}
+
+func TestRelated(t *testing.T) {
+	h := &Admin{
+		Session:  session,
+		Renderer: &TestRenderer{},
+	}
+	h.Register(T6{}, "admin_test.T6", nil)
+	h.Register(T11{}, "admin_test.T11", nil)
+	h.Register(T13{}, "admin_test.T13", nil)
+
+	id := bson.NewObjectId()
+	insert := func(coll string, obj interface{}, oid bson.ObjectId) {
+		c := session.DB("admin_test").C(coll)
+		if err := c.Insert(obj); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { c.Remove(d{"_id": oid}) })
+	}
+
+	//more customers than are shown, and one owner. Level can't hold the id so
+	//it is never searched.
+	for i := 0; i < relatedLimit+2; i++ {
+		oid := bson.NewObjectId()
+		insert("T11", T11{ID: oid, Customer: id}, oid)
+	}
+	oid := bson.NewObjectId()
+	insert("T13", T13{ID: oid, Owner: id.Hex()}, oid)
+
+	rels, err := h.related("admin_test.T6", id.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rels) != 2 {
+		t.Fatalf("Expected related T11 customers and T13 owners. Got %v", rels)
+	}
+
+	if rels[0].Collection != "admin_test.T11" || rels[0].Field != "Customer" {
+		t.Fatalf("Unexpected first relation: %v", rels[0])
+	}
+	if rels[0].Count != relatedLimit+2 || len(rels[0].Objects) != relatedLimit {
+		t.Fatalf("Expected %d customers with %d shown. Got %d with %d shown", relatedLimit+2, relatedLimit, rels[0].Count, len(rels[0].Objects))
+	}
+
+	if rels[1].Collection != "admin_test.T13" || rels[1].Field != "Owner" || rels[1].Count != 1 {
+		t.Fatalf("Unexpected second relation: %v", rels[1])
+	}
+	if owner := rels[1].Objects[0].(*T13); owner.ID != oid {
+		t.Fatalf("Expected owner %s. Got %s", oid, owner.ID)
+	}
+
+	//nothing references a fresh id
+	if rels, err := h.related("admin_test.T6", bson.NewObjectId().Hex()); err != nil || len(rels) != 0 {
+		t.Fatalf("Expected nothing related. Got %v %v", rels, err)
+	}
+}
+
+func TestUnsetPointerReference(t *testing.T) {
+	h := &Admin{}
+	h.Register(T6{}, "admin_test.T6", nil)
+	h.Register(T15{}, "admin_test.T15", nil)
+
+	x := &T15{}
+	if errs, err := h.loadReferences("admin_test.T15", x); err != nil || len(errs) > 0 {
+		t.Fatalf("Expected an unset reference to be skipped. Got %v %v", errs, err)
+	}
+	if links := h.referenceLinks("admin_test.T15", x); len(links) != 0 {
+		t.Fatalf("Expected no links for an unset reference. Got %v", links)
+	}
+}
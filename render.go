@@ -2,9 +2,12 @@ package admin
 
 import (
 	"fmt"
+	"html"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"sort"
 )
 
 //Renderer represents a type that knows how to present content for the admin to
@@ -36,14 +39,67 @@ type Renderer interface {
 	LoggedOut(http.ResponseWriter, *http.Request, BaseContext)
 }
 
+//The pages below are presented through optional interfaces so that existing
+//Renderers keep working. The admin checks its Renderer for the interface of the
+//page, and presents the page with the default html renderer if it doesn't
+//implement it. A NegotiatingRenderer is checked through the renderer it picks
+//for the request.
+
+//DashboardRenderer is implemented by Renderers that present the dashboard. The
+//index page is passed to Dashboard instead of Index if the Renderer implements
+//it.
+type DashboardRenderer interface {
+	Dashboard(http.ResponseWriter, *http.Request, IndexContext)
+}
+
+//AuditRenderer is implemented by Renderers that present the audit log.
+type AuditRenderer interface {
+	Audit(http.ResponseWriter, *http.Request, AuditContext)
+}
+
+//HistoryRenderer is implemented by Renderers that present the history of an
+//object.
+type HistoryRenderer interface {
+	History(http.ResponseWriter, *http.Request, HistoryContext)
+}
+
+//TrashRenderer is implemented by Renderers that present the trash.
+type TrashRenderer interface {
+	Trash(http.ResponseWriter, *http.Request, TrashContext)
+}
+
+//BulkRenderer is implemented by Renderers that present bulk actions.
+type BulkRenderer interface {
+	Bulk(http.ResponseWriter, *http.Request, BulkContext)
+}
+
+//ActionRenderer is implemented by Renderers that present object actions.
+type ActionRenderer interface {
+	Action(http.ResponseWriter, *http.Request, ObjectActionContext)
+}
+
+//WebhookRenderer is implemented by Renderers that present webhook deliveries.
+type WebhookRenderer interface {
+	Webhooks(http.ResponseWriter, *http.Request, WebhookContext)
+}
+
 //DetailContext is the type passed to the Detail method.
 //It comes loaded with the instance of the object found, and a Form that
-//represents the form for the object.
+//represents the form for the object. Files maps the dot separated path of every
+//uploaded File in the object to the File, so that images can be previewed with
+//Reverser.File. Links maps the dot separated path of every reference field to
+//the detail url of the document it references. Related lists the documents in
+//other collections that reference the object. Actions lists the actions the
+//logged in user may run on the object, linked with Reverser.Action.
 type DetailContext struct {
 	BaseContext
 	Collection string
 	Object     interface{}
 	Form       Form
+	Files      map[string]File
+	Links      map[string]string
+	Related    []Related
+	Actions    []ObjectAction
 }
 
 //DeleteContext is the type passed to the Delete method.
@@ -51,7 +107,8 @@ type DetailContext struct {
 //for rendering the object. The renderer should use the Form.Values method to
 //render a readonly display, with a button that adds _sure=yes as a parameter
 //to the same page. Error is the error in attempting to delete the object, if
-//one exists.
+//one exists. Dependents lists the documents in other collections that reference
+//the object so the renderer can warn about them.
 type DeleteContext struct {
 	BaseContext
 	Collection string
@@ -60,16 +117,27 @@ type DeleteContext struct {
 	Success    bool
 	Error      error
 	Form       Form
+	Dependents []Dependent
 }
 
 //ListContext is the type passed in to the List method.
 //It comes loaded with a slice of objects selected by the List view. If no
-//objects match the passed in query, the slice will be nil.
+//objects match the passed in query, the slice will be nil. Files has an entry
+//for every row mapping the column name to any uploaded File in that column for
+//rendering thumbnails, and Links likewise maps the column name of any reference
+//field to the detail url of the document it references. IDs has the id of every
+//row for selecting rows to run one of the Actions on, by posting the action's
+//Name in the _action parameter and the ids in _selected parameters, or _all=yes
+//for every document in the list, to the list url.
 type ListContext struct {
 	BaseContext
 	Collection string
 	Columns    []string
 	Values     [][]string
+	Files      []map[string]File
+	Links      []map[string]string
+	IDs        []string
+	Actions    []BulkAction
 	Objects    []interface{}
 	Pagination Pagination
 }
@@ -78,7 +146,14 @@ type ListContext struct {
 //It comes with booleans indicating if the update was attempted and successful.
 //It also comes with an instance of the object with the matching query.
 //The object always reflects the most recent data in the database.
-//It also comes with a Form that represents the form for the object.
+//It also comes with a Form that represents the form for the object, and an
+//InlineSet for every Inline in the collection's Options that must be rendered
+//inside the same html form. If the object was saved by someone else since the
+//form was loaded, Conflict is true, the Form has the submitted values and
+//Current is the object as it is in the database. Compare then has every field
+//with the value in Current as the Before and the submitted value as the After.
+//The Form carries the Etag of Current, so submitting it again overwrites their
+//changes.
 type UpdateContext struct {
 	BaseContext
 	Collection string
@@ -87,11 +162,17 @@ type UpdateContext struct {
 	Success    bool
 	Error      error
 	Form       Form
+	Inlines    []InlineSet
+	Conflict   bool
+	Current    interface{}
+	Compare    []Change
+	Etag       string
 }
 
 //CreateContext is the type passed in to the Create method.
 //It comes with booleans indicating if the creation was attempted and successful.
-//It also comes with a Form that represents the form for the object.
+//It also comes with a Form that represents the form for the object, and the
+//InlineSets like the UpdateContext.
 type CreateContext struct {
 	BaseContext
 	Collection string
@@ -99,6 +180,7 @@ type CreateContext struct {
 	Success    bool
 	Error      error
 	Form       Form
+	Inlines    []InlineSet
 }
 
 //AuthorizeContext is the type passed in to the Authorize method.
@@ -111,13 +193,124 @@ type AuthorizeContext struct {
 	Error     string
 }
 
+//IndexContext is the type passed in to the Dashboard method.
+//It comes with the dashboard: Counts maps every managed database.collection
+//to its number of documents, Recent has the latest entries in the audit log if
+//it can be searched, and Widgets has the result of every Widget of the admin,
+//to be rendered with the template it names. Counts and widgets are cached for a
+//minute, and any that take too long are left out or have an error.
+type IndexContext struct {
+	BaseContext
+	Counts  map[string]int
+	Recent  []AuditEntry
+	Widgets []WidgetResult
+}
+
+//AuditContext is the type passed in to the Audit method.
+//It comes with a page of the entries in the audit log selected by the Filter,
+//newest first, and the Pagination for the rest of them.
+type AuditContext struct {
+	BaseContext
+	Filter     AuditFilter
+	Entries    []AuditEntry
+	Pagination Pagination
+}
+
+//HistoryContext is the type passed in to the History method.
+//It comes with the current object and its saved Versions, newest first. If the
+//from and to parameters name two versions, Diff has the changes between them.
+//Reverting is done by posting the version number in the revert parameter, and
+//like the UpdateContext it comes with booleans indicating if the revert was
+//attempted and successful, and any errors loading or validating the version.
+//Conflict is set if someone else saved the object while it was being reverted,
+//in which case Object is their version and nothing was saved.
+type HistoryContext struct {
+	BaseContext
+	Collection string
+	Object     interface{}
+	Versions   []Version
+	From       int
+	To         int
+	Diff       []Change
+	Attempted  bool
+	Success    bool
+	Conflict   bool
+	Errors     map[string]interface{}
+}
+
+//TrashContext is the type passed in to the Trash method.
+//It comes with the trashed documents in the collection, most recently deleted
+//first. Documents are restored or purged by posting _action=restore or
+//_action=purge to the url from Reverser.Trash for the document, and like the
+//DeleteContext it comes with booleans indicating if the Action was attempted
+//and successful, and the error if it failed.
+type TrashContext struct {
+	BaseContext
+	Collection string
+	Items      []Trashed
+	Action     string
+	Attempted  bool
+	Success    bool
+	Error      error
+}
+
+//BulkContext is the type passed in to the Bulk method.
+//It comes with the Action posted from the list view and the IDs of the
+//selected documents. The renderer should ask for confirmation by posting the
+//same parameters again with _sure=yes added. Like the DeleteContext it comes
+//with booleans indicating if the action was attempted and successful, and the
+//error if it failed or nothing was selected.
+type BulkContext struct {
+	BaseContext
+	Collection string
+	Action     BulkAction
+	IDs        []string
+	All        bool
+	Attempted  bool
+	Success    bool
+	Error      error
+}
+
+//ObjectActionContext is the type passed in to the Action method.
+//It comes with the object the Action runs on and, if the action takes
+//parameters, a Form for them. The renderer should ask for the parameters or
+//confirmation and post back to the same page. Like the DeleteContext it comes
+//with booleans indicating if the action was attempted and successful, along with
+//the Message returned by the action or the Error if it failed.
+type ObjectActionContext struct {
+	BaseContext
+	Collection string
+	Object     interface{}
+	Action     ObjectAction
+	Form       Form
+	Attempted  bool
+	Success    bool
+	Message    string
+	Error      error
+}
+
+//WebhookContext is the type passed in to the Webhooks method.
+//It comes with a page of the webhook Deliveries, newest first, showing the
+//Status of each and the result of the last attempt.
+type WebhookContext struct {
+	BaseContext
+	Deliveries []Delivery
+	Pagination Pagination
+}
+
 //BaseContext is the type passed in to every Render method. It contains the
 //databases and collections being managed by the admin and information regarding
-//the logged in user.
+//the logged in user. Managed maps every database to its collections, while
+//Groups has them as they should be listed, with their display names from their
+//Options. Locale is the language the page should be presented in, and Theme is
+//the branding it should be presented with.
 type BaseContext struct {
 	Managed  map[string][]string
+	Groups   []Group
 	Reverser Reverser
 	Auth     *AuthSession
+	Locale   Locale
+	Theme    Theme
 }
 
 //Key takes a database and collection and maps it to the key for urls. For
@@ -128,35 +321,60 @@ func (i BaseContext) Key(db, coll string) string {
 
 //TemplateContext is the value passed in as the dot to the template for forms
 //by the default renderer. It has methods for returning the values in the field
-//and any errors in attempting to validate the form.
+//and any errors in attempting to validate the form. Lookups maps the dot
+//separated path of every reference field to the url of the JSON lookup used to
+//autocomplete it. Prefix is prepended to the names of the inputs, and fields
+//in Omit are not rendered, which is used for the forms of an InlineSet. Labels
+//and errors are translated with the Locale.
 type TemplateContext struct {
-	Errors map[string]interface{}
-	Values map[string]interface{}
+	Errors  map[string]interface{}
+	Values  map[string]interface{}
+	Lookups map[string]string
+	Prefix  string
+	Omit    map[string]bool
+	Locale  Locale
 }
 
 //NewTemplateContext creates a new TemplateContext ready to be used.
 func NewTemplateContext() TemplateContext {
-	return TemplateContext{map[string]interface{}{}, map[string]interface{}{}}
+	return TemplateContext{
+		Errors:  map[string]interface{}{},
+		Values:  map[string]interface{}{},
+		Lookups: map[string]string{},
+	}
 }
 
 //Form encapsulates a form with a context with the ability to execute and output
-//the correct html.
+//the correct html. Any hidden values the admin needs submitted with the form,
+//like the etag on the update page, are output before the form's fields.
 type Form struct {
 	object  Formable
 	context TemplateContext
 	logger  *log.Logger
+	hidden  url.Values
 }
 
 //Execute calls the template with the context and executes it to the writer
 func (f Form) Execute(w io.Writer) (err error) {
-	_, err = io.WriteString(w, f.object.GetForm(f.context))
+	_, err = io.WriteString(w, f.ExecuteText())
 	return
 }
 
 //ExecuteText is for use in templates. It returns the string containing the
 //output of Execute.
 func (f Form) ExecuteText() string {
-	return f.object.GetForm(f.context)
+	keys := make([]string, 0, len(f.hidden))
+	for key := range f.hidden {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var inputs string
+	for _, key := range keys {
+		inputs += fmt.Sprintf(`<input type="hidden" name="%s" value="%s">`,
+			html.EscapeString(key), html.EscapeString(f.hidden.Get(key)))
+	}
+	return inputs + f.object.GetForm(f.context)
 }
 
 //Values returns the values map for the Form. This is useful for the Delete
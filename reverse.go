@@ -26,12 +26,7 @@ func (r Reverser) idFor(thing interface{}) string {
 		panic(fmt.Errorf("Don't know how to get the id for a %T", thing))
 	}
 
-	id := val.Field(idx).Interface()
-	switch t := id.(type) {
-	case hexable:
-		return t.Hex()
-	}
-	return fmt.Sprint(id)
+	return formatValue(val.Field(idx))
 }
 
 //collFor consults the admins object_coll cache to find the collection for the
@@ -45,6 +40,13 @@ func (r Reverser) collFor(thing interface{}) string {
 	return col
 }
 
+//ID returns the formatted id of the passed in object, for building urls that
+//take an id in templates.
+func (r Reverser) ID(thing interface{}) string {
+	r.admin.init()
+	return r.idFor(thing)
+}
+
 //CreateObj returns the url to create an object of the same type as the passed
 //in object.
 func (r Reverser) CreateObj(thing interface{}) string {
@@ -116,6 +118,106 @@ func (r Reverser) Update(coll string, id string) string {
 	return path.Join(r.admin.Prefix, r.admin.Routes["update"], coll, id)
 }
 
+//File returns the url to the contents of an uploaded file. It returns the empty
+//string if the file is empty or the files route is not configured.
+func (r Reverser) File(f File) string {
+	r.admin.init()
+	route, ok := r.admin.Routes["files"]
+	if !ok || f.ID == "" {
+		return ""
+	}
+	return path.Join(r.admin.Prefix, route, f.ID)
+}
+
+//Lookup returns the url of the JSON lookup for autocompleting references to the
+//given database/collection. It returns the empty string if the lookup route is
+//not configured.
+func (r Reverser) Lookup(coll string) string {
+	r.admin.init()
+	route, ok := r.admin.Routes["lookup"]
+	if !ok {
+		return ""
+	}
+	return path.Join(r.admin.Prefix, route, coll)
+}
+
+//Audit returns the url of the audit log for the given database/collection and
+//object id. The id may be empty for the whole collection, and both for the whole
+//log. It returns the empty string if the audit route is not configured or the
+//Audit can't be searched.
+func (r Reverser) Audit(coll string, id string) string {
+	r.admin.init()
+	route, ok := r.admin.Routes["audit"]
+	if !ok {
+		return ""
+	}
+	if _, ok := r.admin.Audit.(AuditSearcher); !ok {
+		return ""
+	}
+	return path.Join(r.admin.Prefix, route, coll, id)
+}
+
+//History returns the url of the saved versions of the object given by the
+//database/collection and id. It returns the empty string if the history route
+//is not configured or there is no VersionStore.
+func (r Reverser) History(coll string, id string) string {
+	r.admin.init()
+	route, ok := r.admin.Routes["history"]
+	if !ok || r.admin.Versions == nil {
+		return ""
+	}
+	return path.Join(r.admin.Prefix, route, coll, id)
+}
+
+//Trash returns the url of the trash for the given database/collection, or of
+//the trashed object with the id for restoring or purging it. It returns the
+//empty string if the trash route is not configured or the collection doesn't
+//use SoftDelete.
+func (r Reverser) Trash(coll string, id string) string {
+	r.admin.init()
+	route, ok := r.admin.Routes["trash"]
+	if !ok || !r.admin.hasType(coll) || !r.admin.types[coll].Options.SoftDelete {
+		return ""
+	}
+	return path.Join(r.admin.Prefix, route, coll, id)
+}
+
+//Action returns the url of the action with the name on the object given by the
+//database/collection and id. It returns the empty string if the action route is
+//not configured.
+func (r Reverser) Action(coll, id, name string) string {
+	r.admin.init()
+	route, ok := r.admin.Routes["action"]
+	if !ok {
+		return ""
+	}
+	return path.Join(r.admin.Prefix, route, coll, id, name)
+}
+
+//Webhooks returns the url of the log of webhook deliveries. It returns the
+//empty string if the webhooks route is not configured or there are no Webhooks.
+func (r Reverser) Webhooks() string {
+	r.admin.init()
+	route, ok := r.admin.Routes["webhooks"]
+	if !ok || len(r.admin.Webhooks) == 0 {
+		return ""
+	}
+	return path.Join(r.admin.Prefix, route)
+}
+
+//Asset returns the fingerprinted url of the asset with the name, like
+//"admin.css", which can be cached forever. It returns the empty string if the
+//asset does not exist or the assets route is not configured.
+func (r Reverser) Asset(name string) string {
+	r.admin.init()
+	route, ok := r.admin.Routes["assets"]
+	as, ex := r.admin.assets[name]
+	if !ok || !ex {
+		return ""
+	}
+	return path.Join(r.admin.Prefix, route, as.url)
+}
+
 func (r Reverser) Login() string {
 	r.admin.init()
 	return path.Join(r.admin.Prefix, r.admin.Routes["auth"], "login")
@@ -1,6 +1,7 @@
 package admin
 
 import (
+	"io/ioutil"
 	"launchpad.net/mgo/bson"
 	"testing"
 )
@@ -160,3 +161,63 @@ func TestReverseCustomSpecified(t *testing.T) {
 		t.Errorf("Expected %q. Got %q.", e, c)
 	}
 }
+
+func TestReverseAuditSearchable(t *testing.T) {
+	h := &Admin{Session: session, Audit: &WriterAudit{W: ioutil.Discard}}
+	r := Reverser{h}
+	h.Register(T{}, "admin_test.T", nil)
+
+	if c := r.Audit("admin_test.T", ""); c != "" {
+		t.Errorf("Expected no link to an audit log that can't be searched. Got %q.", c)
+	}
+
+	h.Audit = &FileAudit{Path: "audit.log"}
+	if c, e := r.Audit("admin_test.T", ""), "/audit/admin_test.T"; c != e {
+		t.Errorf("Expected %q. Got %q.", e, c)
+	}
+}
+
+func TestReverseHistoryVersions(t *testing.T) {
+	h := &Admin{Session: session}
+	r := Reverser{h}
+	h.Register(T{}, "admin_test.T", nil)
+
+	id := "ffffffffffffffffffffffff"
+	if c := r.History("admin_test.T", id); c != "" {
+		t.Errorf("Expected no link to the history without a VersionStore. Got %q.", c)
+	}
+
+	h.Versions = CollectionVersions{Session: session, DB: "admin_test", Collection: "versions"}
+	if c, e := r.History("admin_test.T", id), "/history/admin_test.T/"+id; c != e {
+		t.Errorf("Expected %q. Got %q.", e, c)
+	}
+}
+
+func TestReverseTrashSoftDelete(t *testing.T) {
+	h := &Admin{Session: session}
+	r := Reverser{h}
+	h.Register(T{}, "admin_test.T", nil)
+	h.Register(T6{}, "admin_test.T6", &Options{SoftDelete: true})
+
+	if c := r.Trash("admin_test.T", ""); c != "" {
+		t.Errorf("Expected no link to the trash without SoftDelete. Got %q.", c)
+	}
+	if c, e := r.Trash("admin_test.T6", ""), "/trash/admin_test.T6"; c != e {
+		t.Errorf("Expected %q. Got %q.", e, c)
+	}
+}
+
+func TestReverseWebhooksConfigured(t *testing.T) {
+	h := &Admin{Session: session}
+	r := Reverser{h}
+	h.Register(T{}, "admin_test.T", nil)
+
+	if c := r.Webhooks(); c != "" {
+		t.Errorf("Expected no link to the deliveries without Webhooks. Got %q.", c)
+	}
+
+	h.Webhooks = []Webhook{{Name: "hook", URL: "http://localhost/hook"}}
+	if c, e := r.Webhooks(), "/webhooks"; c != e {
+		t.Errorf("Expected %q. Got %q.", e, c)
+	}
+}
@@ -1,8 +1,12 @@
 package admin
 
 import (
+	"fmt"
+	"github.com/zeebo/admin/forms"
 	"launchpad.net/mgo/bson"
 	"net/url"
+	"strings"
+	"time"
 )
 
 //T is the most basic type possible
@@ -82,3 +86,174 @@ func (t T7) GetForm(ctx TemplateContext) string { return `` }
 func (t T7) Validate() ValidationErrors         { return nil }
 
 var _ Formable = T7{}
+
+//Money is a type that implements FieldCodec
+type Money int64
+
+func (m Money) FormatField() string      { return fmt.Sprintf("%d.%02d", m/100, m%100) }
+func (m Money) FieldWidget() forms.Field { return forms.Text }
+func (m *Money) ParseField(data string) error {
+	var dollars, cents int64
+	if _, err := fmt.Sscanf(data, "%d.%d", &dollars, &cents); err != nil {
+		return err
+	}
+	*m = Money(dollars*100 + cents)
+	return nil
+}
+
+var _ FieldCodec = new(Money)
+
+//Tags is a type that cannot be handled by the loader but implements the
+//encoding.TextMarshaler and encoding.TextUnmarshaler pair.
+type Tags []string
+
+func (t Tags) MarshalText() ([]byte, error) { return []byte(strings.Join(t, ",")), nil }
+func (t *Tags) UnmarshalText(data []byte) error {
+	*t = strings.Split(string(data), ",")
+	return nil
+}
+
+//T8 is a type with fields that have their own codecs
+type T8 struct {
+	ID    bson.ObjectId `bson:"_id,omitempty"`
+	Price Money
+	Tags  Tags
+	When  time.Time
+}
+
+func (t T8) GetForm(ctx TemplateContext) string { return `` }
+func (t T8) Validate() ValidationErrors         { return nil }
+
+var _ Formable = T8{}
+
+//T9 is a type with file fields
+type T9 struct {
+	ID     bson.ObjectId `bson:"_id,omitempty"`
+	Name   string
+	Avatar File
+	Nested struct {
+		Doc *File
+	}
+}
+
+func (t T9) GetForm(ctx TemplateContext) string { return `` }
+func (t T9) Validate() ValidationErrors         { return nil }
+
+var _ Formable = T9{}
+
+//T10 is a type with fields that have fixed choices
+type T10 struct {
+	ID     bson.ObjectId `bson:"_id,omitempty"`
+	Status string        `choices:"draft:Draft,published:Published"`
+	Level  int
+	Inner  struct {
+		Color string `widget:"Radio" choices:"red,blue"`
+	}
+}
+
+func (t T10) GetForm(ctx TemplateContext) string { return `` }
+func (t T10) Validate() ValidationErrors         { return nil }
+func (t T10) Choices(field string) []forms.Item {
+	if field == "Level" {
+		return []forms.Item{{Label: "Low", Value: "1"}, {Label: "High", Value: "2"}}
+	}
+	return nil
+}
+
+var _ Formable = T10{}
+var _ forms.Chooser = T10{}
+
+//T11 is a type with references to other collections
+type T11 struct {
+	ID       bson.ObjectId `bson:"_id,omitempty"`
+	Customer bson.ObjectId `bson:"cust" ref:"admin_test.T6"`
+	Shipping struct {
+		Address bson.ObjectId
+	}
+}
+
+func (t T11) GetForm(ctx TemplateContext) string { return `` }
+func (t T11) Validate() ValidationErrors         { return nil }
+
+var _ Formable = T11{}
+
+//T12 is a type with a version field
+type T12 struct {
+	ID    bson.ObjectId `bson:"_id,omitempty"`
+	Name  string        `bson:",omitempty"`
+	Rev   int
+	Inner struct {
+		Note string
+	}
+}
+
+func (t T12) GetForm(ctx TemplateContext) string { return `` }
+func (t T12) Validate() ValidationErrors         { return nil }
+
+var _ Formable = T12{}
+
+//T13 is a type with references stored in fields of other types
+type T13 struct {
+	ID    bson.ObjectId `bson:"_id,omitempty"`
+	Owner string        `ref:"admin_test.T6"`
+	Level int           `ref:"admin_test.T6"`
+}
+
+func (t T13) GetForm(ctx TemplateContext) string { return `` }
+func (t T13) Validate() ValidationErrors         { return nil }
+
+var _ Formable = T13{}
+
+//T14 is a type with every hook. It records the hooks called on it in hookCalls
+//and refuses to be saved or deleted when its Name is "refuse".
+type T14 struct {
+	ID   bson.ObjectId `bson:"_id,omitempty"`
+	Name string
+}
+
+var hookCalls []string
+
+func (t T14) GetForm(ctx TemplateContext) string { return `` }
+func (t T14) Validate() ValidationErrors         { return nil }
+
+func (t *T14) refuse(hook string) ValidationErrors {
+	hookCalls = append(hookCalls, hook)
+	if t.Name == "refuse" {
+		return ValidationErrors{"Name": "is refused"}
+	}
+	return nil
+}
+
+func (t *T14) BeforeSave(ctx ActionContext) ValidationErrors   { return t.refuse("BeforeSave") }
+func (t *T14) BeforeDelete(ctx ActionContext) ValidationErrors { return t.refuse("BeforeDelete") }
+func (t *T14) AfterSave(ctx ActionContext)                     { hookCalls = append(hookCalls, "AfterSave") }
+func (t *T14) AfterDelete(ctx ActionContext)                   { hookCalls = append(hookCalls, "AfterDelete") }
+
+var (
+	_ BeforeSaver   = &T14{}
+	_ AfterSaver    = &T14{}
+	_ BeforeDeleter = &T14{}
+	_ AfterDeleter  = &T14{}
+)
+
+//T15 is a type with an optional reference
+type T15 struct {
+	ID       bson.ObjectId  `bson:"_id,omitempty"`
+	Customer *bson.ObjectId `ref:"admin_test.T6"`
+}
+
+func (t T15) GetForm(ctx TemplateContext) string { return `` }
+func (t T15) Validate() ValidationErrors         { return nil }
+
+var _ Formable = T15{}
+
+//T16 is a type with an optional version
+type T16 struct {
+	ID  bson.ObjectId `bson:"_id,omitempty"`
+	Ver *int
+}
+
+func (t T16) GetForm(ctx TemplateContext) string { return `` }
+func (t T16) Validate() ValidationErrors         { return nil }
+
+var _ Formable = T16{}
@@ -88,3 +88,45 @@ func (r *TestRenderer) LoggedOut(w http.ResponseWriter, req *http.Request, c Bas
 		Params: c,
 	})
 }
+
+func (r *TestRenderer) History(w http.ResponseWriter, req *http.Request, c HistoryContext) {
+	r.Calls = append(r.Calls, TestCall{
+		Type:   "History",
+		Params: c,
+	})
+}
+
+func (r *TestRenderer) Trash(w http.ResponseWriter, req *http.Request, c TrashContext) {
+	r.Calls = append(r.Calls, TestCall{
+		Type:   "Trash",
+		Params: c,
+	})
+}
+
+func (r *TestRenderer) Bulk(w http.ResponseWriter, req *http.Request, c BulkContext) {
+	r.Calls = append(r.Calls, TestCall{
+		Type:   "Bulk",
+		Params: c,
+	})
+}
+
+func (r *TestRenderer) Action(w http.ResponseWriter, req *http.Request, c ObjectActionContext) {
+	r.Calls = append(r.Calls, TestCall{
+		Type:   "Action",
+		Params: c,
+	})
+}
+
+func (r *TestRenderer) Webhooks(w http.ResponseWriter, req *http.Request, c WebhookContext) {
+	r.Calls = append(r.Calls, TestCall{
+		Type:   "Webhooks",
+		Params: c,
+	})
+}
+
+func (r *TestRenderer) Audit(w http.ResponseWriter, req *http.Request, c AuditContext) {
+	r.Calls = append(r.Calls, TestCall{
+		Type:   "Audit",
+		Params: c,
+	})
+}
@@ -0,0 +1,106 @@
+package admin
+
+import "html/template"
+
+//Theme is the branding of the pages of the default renderer. Title is shown in
+//the header and the title of every page, after the image at the Logo url if it
+//has one. The colors are CSS colors, and any left empty are taken from the
+//LightTheme. Banner is text shown in a bar of BannerColor across the top of
+//every page, to tell apart admins for different environments. Stylesheets and
+//Scripts are urls included in every page, and CSS and JS are included inline
+//after them, so they can restyle or extend the built in templates.
+type Theme struct {
+	Title string
+	Logo  string
+
+	Background string
+	Text       string
+	Header     string
+	HeaderText string
+	Link       string
+	Border     string
+	Error      string
+	Success    string
+
+	Banner      string
+	BannerColor string
+
+	Stylesheets []string
+	Scripts     []string
+	CSS         template.CSS
+	JS          template.JS
+}
+
+//LightTheme is the theme used when the Admin has none.
+var LightTheme = Theme{
+	Title:       "Admin",
+	Background:  "#fff",
+	Text:        "#222",
+	Header:      "#334",
+	HeaderText:  "#fff",
+	Link:        "#15c",
+	Border:      "#ddd",
+	Error:       "#a00",
+	Success:     "#070",
+	BannerColor: "#c00",
+}
+
+//DarkTheme is a theme with light text on a dark background.
+var DarkTheme = Theme{
+	Title:       "Admin",
+	Background:  "#1e1f24",
+	Text:        "#ddd",
+	Header:      "#111",
+	HeaderText:  "#eee",
+	Link:        "#8ab4f8",
+	Border:      "#444",
+	Error:       "#f77",
+	Success:     "#7c7",
+	BannerColor: "#b00",
+}
+
+//ContrastTheme is a high contrast theme of black on white.
+var ContrastTheme = Theme{
+	Title:       "Admin",
+	Background:  "#fff",
+	Text:        "#000",
+	Header:      "#000",
+	HeaderText:  "#fff",
+	Link:        "#00e",
+	Border:      "#000",
+	Error:       "#c00",
+	Success:     "#060",
+	BannerColor: "#c00",
+}
+
+//ProductionBanner returns the theme with a red PRODUCTION banner.
+func (t Theme) ProductionBanner() Theme {
+	t.Banner, t.BannerColor = "PRODUCTION", "#c00"
+	return t
+}
+
+//withDefaults returns the theme with anything left empty taken from the
+//LightTheme.
+func (t Theme) withDefaults() Theme {
+	defaults := []struct {
+		field *string
+		value string
+	}{
+		{&t.Title, LightTheme.Title},
+		{&t.Background, LightTheme.Background},
+		{&t.Text, LightTheme.Text},
+		{&t.Header, LightTheme.Header},
+		{&t.HeaderText, LightTheme.HeaderText},
+		{&t.Link, LightTheme.Link},
+		{&t.Border, LightTheme.Border},
+		{&t.Error, LightTheme.Error},
+		{&t.Success, LightTheme.Success},
+		{&t.BannerColor, LightTheme.BannerColor},
+	}
+	for _, d := range defaults {
+		if *d.field == "" {
+			*d.field = d.value
+		}
+	}
+	return t
+}
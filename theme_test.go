@@ -0,0 +1,22 @@
+package admin
+
+import "testing"
+
+func TestThemeDefaults(t *testing.T) {
+	theme := Theme{Title: "Shop", Header: "#060", Banner: "STAGING"}.withDefaults()
+	if theme.Title != "Shop" || theme.Header != "#060" || theme.Banner != "STAGING" {
+		t.Errorf("Lost the configured fields: %+v", theme)
+	}
+	if theme.Background != LightTheme.Background || theme.BannerColor != LightTheme.BannerColor {
+		t.Errorf("Expected the light colors for empty fields: %+v", theme)
+	}
+
+	if theme := (Theme{}).withDefaults(); theme.Title != "Admin" || theme.Text != LightTheme.Text {
+		t.Errorf("Expected the LightTheme: %+v", theme)
+	}
+
+	dark := DarkTheme.ProductionBanner().withDefaults()
+	if dark.Background != DarkTheme.Background || dark.Banner != "PRODUCTION" {
+		t.Errorf("Unexpected dark theme: %+v", dark)
+	}
+}
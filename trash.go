@@ -0,0 +1,246 @@
+package admin
+
+import (
+	"launchpad.net/mgo/bson"
+	"net/http"
+	"time"
+)
+
+//trashKey is the key set to the time a document was deleted in collections
+//using soft deletes.
+const trashKey = "_deleted_at"
+
+//trashPurgeInterval is how often trashed documents past their retention are
+//purged in the background.
+const trashPurgeInterval = time.Hour
+
+//Actions recorded in an AuditEntry for collections using soft deletes.
+const (
+	ActionRestore = "restore"
+	ActionPurge   = "purge"
+)
+
+//Trashed is a document in the trash along with the time it was deleted.
+type Trashed struct {
+	Object  interface{}
+	Deleted time.Time
+}
+
+//liveQuery adds the condition hiding trashed documents to the query if the
+//collection uses soft deletes.
+func (a *Admin) liveQuery(coll string, q bson.M) bson.M {
+	if !a.types[coll].Options.SoftDelete {
+		return q
+	}
+	if q == nil {
+		q = bson.M{}
+	}
+	q[trashKey] = bson.M{"$exists": false}
+	return q
+}
+
+//trashQuery returns the query for trashed documents in a collection, merged
+//with the conditions in q.
+func trashQuery(q bson.M) bson.M {
+	if q == nil {
+		q = bson.M{}
+	}
+	q[trashKey] = bson.M{"$exists": true}
+	return q
+}
+
+//decodeInto loads the raw document into the object the same way mgo would.
+func decodeInto(doc bson.M, t interface{}) error {
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(data, t)
+}
+
+//trashed returns every document in the trash of the collection, most recently
+//deleted first.
+func (a *Admin) trashed(coll string) ([]Trashed, error) {
+	var items []Trashed
+	iter := a.collFor(coll).Find(trashQuery(nil)).Sort(bson.M{trashKey: -1}).Iter()
+	for {
+		var doc bson.M
+		if !iter.Next(&doc) {
+			break
+		}
+
+		t := a.newType(coll)
+		if err := decodeInto(doc, t); err != nil {
+			return nil, err
+		}
+		deleted, _ := doc[trashKey].(time.Time)
+		items = append(items, Trashed{t, deleted})
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+//moveToTrash marks the document with the id as deleted.
+func (a *Admin) moveToTrash(coll string, id interface{}) error {
+	return a.collFor(coll).Update(bson.M{"_id": id}, bson.M{
+		"$set": bson.M{trashKey: time.Now()},
+	})
+}
+
+//restore takes the document with the formatted id out of the trash.
+func (a *Admin) restore(req *http.Request, coll, id string) error {
+	q, err := a.idQuery(coll, id)
+	if err != nil {
+		return err
+	}
+
+	err = a.collFor(coll).Update(trashQuery(bson.M{"_id": q}), bson.M{
+		"$unset": bson.M{trashKey: 1},
+	})
+	if err != nil {
+		return err
+	}
+
+	a.audit(req, ActionRestore, coll, id, nil, nil)
+	return nil
+}
+
+//purge permanently removes the document with the formatted id from the trash,
+//along with any uploaded files.
+func (a *Admin) purge(req *http.Request, coll, id string) error {
+	q, err := a.idQuery(coll, id)
+	if err != nil {
+		return err
+	}
+
+	c, t := a.collFor(coll), a.newType(coll)
+	if err := c.Find(trashQuery(bson.M{"_id": q})).One(t); err != nil {
+		return err
+	}
+	if err := c.Remove(bson.M{"_id": q}); err != nil {
+		return err
+	}
+
+	a.deleteFiles(t)
+	before, _ := formValues(t)
+	a.audit(req, ActionPurge, coll, id, before, nil)
+	return nil
+}
+
+//PurgeTrash permanently removes every trashed document that has been in the
+//trash longer than the Retention of its collection. It is called periodically
+//by the admin when any collection has a Retention, but can be called to purge
+//on demand. Purges are recorded as the SystemUser and don't call any hooks.
+func (a *Admin) PurgeTrash() error {
+	a.init()
+
+	reverser := Reverser{a}
+	for coll, info := range a.types {
+		if !info.Options.SoftDelete || info.Options.Retention <= 0 {
+			continue
+		}
+
+		cutoff := time.Now().Add(-info.Options.Retention)
+		iter := a.collFor(coll).Find(bson.M{trashKey: bson.M{"$lt": cutoff}}).Iter()
+
+		var expired []Formable
+		for {
+			t := a.newType(coll)
+			if !iter.Next(t) {
+				break
+			}
+			expired = append(expired, t)
+		}
+		if err := iter.Err(); err != nil {
+			return err
+		}
+
+		for _, t := range expired {
+			if err := a.purge(nil, coll, reverser.idFor(t)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//hasRetention returns if any collection purges its trash.
+func (a *Admin) hasRetention() bool {
+	for _, info := range a.types {
+		if info.Options.SoftDelete && info.Options.Retention > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+//purgeTrash calls PurgeTrash every trashPurgeInterval until the admin is
+//closed.
+func (a *Admin) purgeTrash() {
+	ticker := time.NewTicker(trashPurgeInterval)
+	defer ticker.Stop()
+	for {
+		if err := a.PurgeTrash(); err != nil {
+			a.logger.Printf("Error purging trash: %s", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-a.done:
+			return
+		}
+	}
+}
+
+//Presents the trash of a collection using soft deletes, and restores or purges
+//the document in the path when posted with _action=restore or _action=purge
+func (a *Admin) trash(w http.ResponseWriter, req *http.Request) {
+	coll, id := parseRequest(req.URL.Path)
+
+	//ensure we have a collection that uses the trash
+	if coll == "" || !a.hasType(coll) || !a.types[coll].Options.SoftDelete {
+		a.Renderer.NotFound(w, req)
+		return
+	}
+
+	var attempted, success bool
+	var action string
+	var err error
+	if req.Method == "POST" {
+		//ensure we have an id to act on
+		if id == "" {
+			a.Renderer.NotFound(w, req)
+			return
+		}
+
+		req.ParseForm()
+		switch action = req.Form.Get("_action"); action {
+		case "restore":
+			err = a.restore(req, coll, id)
+		case "purge":
+			err = a.purge(req, coll, id)
+		default:
+			a.Renderer.NotFound(w, req)
+			return
+		}
+		attempted, success = true, err == nil
+	}
+
+	//grab the trash. Keep err as the error acting on the document.
+	items, terr := a.trashed(coll)
+	if terr != nil {
+		a.Renderer.InternalError(w, req, terr)
+		return
+	}
+
+	a.renderTrash(w, req, TrashContext{
+		BaseContext: a.baseContext(req),
+		Collection:  coll,
+		Items:       items,
+		Action:      action,
+		Attempted:   attempted,
+		Success:     success,
+		Error:       err,
+	})
+}
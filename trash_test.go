@@ -0,0 +1,84 @@
+package admin
+
+import (
+	"launchpad.net/mgo/bson"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLiveQuery(t *testing.T) {
+	h := &Admin{}
+	h.Register(T6{}, "admin_test.T6", nil)
+	h.Register(T10{}, "admin_test.T10", &Options{SoftDelete: true})
+
+	if q := h.liveQuery("admin_test.T6", nil); q != nil {
+		t.Fatalf("Expected no query without soft deletes. Got %v", q)
+	}
+
+	expected := bson.M{"_id": 1, trashKey: bson.M{"$exists": false}}
+	if q := h.liveQuery("admin_test.T10", bson.M{"_id": 1}); !reflect.DeepEqual(q, expected) {
+		t.Fatalf("Expected %v. Got %v", expected, q)
+	}
+
+	expected = bson.M{trashKey: bson.M{"$exists": true}}
+	if q := trashQuery(nil); !reflect.DeepEqual(q, expected) {
+		t.Fatalf("Expected %v. Got %v", expected, q)
+	}
+}
+
+func TestHasRetention(t *testing.T) {
+	h := &Admin{}
+	h.Register(T6{}, "admin_test.T6", &Options{Retention: time.Hour})
+	if h.hasRetention() {
+		t.Fatal("Retention without soft deletes purges the trash")
+	}
+
+	h.Register(T10{}, "admin_test.T10", &Options{SoftDelete: true, Retention: time.Hour})
+	if !h.hasRetention() {
+		t.Fatal("Expected the trash to be purged")
+	}
+}
+
+func TestDecodeInto(t *testing.T) {
+	id := bson.ObjectIdHex("4f07c34779bf562daff8640c")
+	doc := bson.M{
+		"_id":    id,
+		"status": "draft",
+		"inner":  bson.M{"color": "red"},
+		trashKey: time.Now(),
+	}
+
+	var x T10
+	if err := decodeInto(doc, &x); err != nil {
+		t.Fatal(err)
+	}
+	if x.ID != id || x.Status != "draft" || x.Inner.Color != "red" {
+		t.Fatalf("Document not decoded: %+v", x)
+	}
+}
+
+func TestDetailTrashed(t *testing.T) {
+	r := &TestRenderer{}
+	h := &Admin{
+		Session:  session,
+		Renderer: r,
+	}
+	h.Register(T10{}, "admin_test.T10", &Options{SoftDelete: true})
+
+	id := bson.NewObjectId()
+	c := session.DB("admin_test").C("T10")
+	if err := c.Insert(bson.M{"_id": id, trashKey: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Remove(bson.M{"_id": id}) })
+
+	w := Get(t, h, "/detail/admin_test.T10/"+id.Hex())
+	if w.Status != http.StatusNotFound {
+		t.Fatalf("Expected 404 got %d", w.Status)
+	}
+	if r.Last().Type != "NotFound" {
+		t.Fatalf("Wrong Renderer called. Expected NotFound got %s", r.Last().Type)
+	}
+}
@@ -4,12 +4,73 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"time"
 )
 
 //Options when adding a collection to the admin
 type Options struct {
 	//Which columns to display/order to display them - nil means all
 	Columns []string
+
+	//Limits for uploads into File fields keyed by the dot separated path to the
+	//field. Fields without an entry use DefaultUploadLimits.
+	Uploads map[string]UploadLimits
+
+	//References maps the dot separated path of a field to the database/collection
+	//of the documents whose ids it stores, in addition to any ref tags.
+	References map[string]string
+
+	//Fields searched case insensitively by the lookup handler when
+	//autocompleting references to this collection. Ids are always searched.
+	SearchFields []string
+
+	//Documents in other collections referencing this one that are edited along
+	//with it on the update and create pages.
+	Inlines []Inline
+
+	//If SoftDelete is true, deleting a document moves it to the trash instead
+	//of removing it. Trashed documents are hidden from lists and can be
+	//restored or purged from the trash page.
+	SoftDelete bool
+
+	//How long documents stay in the trash before they are purged in the
+	//background. Zero keeps them until they are purged by hand.
+	Retention time.Duration
+
+	//VersionField is the dot separated path to an integer field incremented on
+	//every update and checked to detect conflicting updates. Updates must send
+	//the version they were made from, and are conflicts without it. If empty, a
+	//hash of the values of the document is used instead.
+	VersionField string
+
+	//Actions that can be run on many documents selected in the list view, in
+	//addition to the built in delete.
+	BulkActions []BulkAction
+
+	//Actions that can be run on a single document from its detail page.
+	Actions []ObjectAction
+
+	//Templates maps a page, like "list" or "detail", to a file in the default
+	//renderer's template directory overriding it for this collection. Without
+	//an entry, a file named like "list.db.coll.html" is used if there is one.
+	Templates map[string]string
+
+	//Name is how the collection is shown on the index and in the navigation,
+	//and Plural how a list of its documents is. Without a Name both are the
+	//collection part of "db.coll", and Plural defaults to Name with an s.
+	Name   string
+	Plural string
+
+	//Description is shown with the collection on the index, and Icon is the
+	//url of an image shown next to its name, or the name of an asset.
+	Description string
+	Icon        string
+
+	//Group is the heading the collection is listed under on the index and in
+	//the navigation, the database by default. Collections are sorted by Order
+	//and then Name within a group, and groups by the lowest Order in them.
+	Group string
+	Order int
 }
 
 //findIds finds the index locations of the type matching the columns passed in.
@@ -52,8 +113,10 @@ func findIds(typ reflect.Type, columns []string) []int {
 //Stores info about a specific collection, like the type of the object it
 //represents and any options used in specifying the type
 type collectionInfo struct {
-	Type      reflect.Type
-	ColumnIds []int
+	Type       reflect.Type
+	ColumnIds  []int
+	Options    Options
+	References map[string]string
 }
 
 //Registers the type/collection pair in the admin. Panics if two types are mapped
@@ -61,7 +124,10 @@ type collectionInfo struct {
 //Panics if no database is specified. Panics if the template returned by the Formable
 //has any compilation errors. Panics if the type cannot be handled by the loading
 //engine (must be composed of valid types. See Load for discussion on which types
-//are valid.) Panics if it can't find a field with a bson:_id tag.
+//are valid.) Panics if it can't find a field with a bson:_id tag. Fields may
+//reference documents in other registered collections with a ref tag or the
+//References option. The first request to the admin panics if a referenced or
+//inlined collection was never registered.
 func (a *Admin) Register(typ Formable, dbcoll string, opt *Options) {
 	if a.types == nil {
 		a.types = make(map[string]collectionInfo)
@@ -89,11 +155,8 @@ func (a *Admin) Register(typ Formable, dbcoll string, opt *Options) {
 	//now ensure that we can find out where the id is. Look for a bson:_id tag
 	var i int
 	for i = 0; i < t.NumField(); i++ {
-		field := t.Field(i)
-		for _, tag := range strings.Split(field.Tag.Get("bson"), ",") {
-			if tag == "_id" {
-				goto found
-			}
+		if isIdField(t.Field(i)) {
+			goto found
 		}
 	}
 	panic("Unable to find a field that is an id. Be sure to add a bson:_id to your struct")
@@ -103,15 +166,35 @@ found:
 	a.object_id[t] = i
 	a.object_coll[t] = dbcoll
 
-	var ids []int
-
 	if opt == nil {
-		ids = findIds(t, nil)
-	} else {
-		ids = findIds(t, opt.Columns)
+		opt = &Options{}
+	}
+
+	checkVersionField(t, opt)
+	checkBulkActions(t, opt)
+	checkObjectActions(t, opt)
+	checkTemplates(t, opt)
+
+	//copy the inlines so resolving them doesn't modify the passed in options
+	opts := *opt
+	opts.Inlines = append([]Inline(nil), opt.Inlines...)
+
+	a.types[dbcoll] = collectionInfo{
+		Type:       t,
+		ColumnIds:  findIds(t, opt.Columns),
+		Options:    opts,
+		References: findReferences(t, opt),
 	}
+}
 
-	a.types[dbcoll] = collectionInfo{t, ids}
+//isIdField returns if the struct field is tagged as the bson _id.
+func isIdField(field reflect.StructField) bool {
+	for _, tag := range strings.Split(field.Tag.Get("bson"), ",") {
+		if tag == "_id" {
+			return true
+		}
+	}
+	return false
 }
 
 //hasType returns if the database/collection pair has been registered.
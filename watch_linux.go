@@ -0,0 +1,47 @@
+//go:build linux
+
+package admin
+
+import (
+	"os"
+	"syscall"
+)
+
+//watchDir returns a channel that receives a value whenever a file in the
+//directory is created, changed, moved or removed, using inotify. Changes made
+//while a value is waiting are coalesced. It stops watching when done is closed.
+func watchDir(dir string, done <-chan struct{}) (<-chan bool, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	mask := uint32(syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_CLOSE_WRITE |
+		syscall.IN_MODIFY | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO)
+	if _, err := syscall.InotifyAddWatch(fd, dir, mask); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+
+	//a non blocking fd goes through the runtime poller, so closing the file
+	//wakes up the pending read
+	file := os.NewFile(uintptr(fd), "inotify")
+	go func() {
+		<-done
+		file.Close()
+	}()
+
+	changes := make(chan bool, 1)
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			if _, err := file.Read(buf); err != nil {
+				return
+			}
+			select {
+			case changes <- true:
+			default:
+			}
+		}
+	}()
+	return changes, nil
+}
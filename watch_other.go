@@ -0,0 +1,13 @@
+//go:build !linux
+
+package admin
+
+import (
+	"fmt"
+)
+
+//watchDir is only supported with inotify, so callers fall back to checking for
+//changes periodically.
+func watchDir(dir string, done <-chan struct{}) (<-chan bool, error) {
+	return nil, fmt.Errorf("Notifications of changes are not supported")
+}
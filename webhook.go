@@ -0,0 +1,409 @@
+package admin
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"launchpad.net/mgo"
+	"launchpad.net/mgo/bson"
+	"net/http"
+	"time"
+)
+
+const (
+	webhookInterval    = time.Minute      //how often the queue is checked for retries
+	webhookBackoff     = 30 * time.Second //delay before the first retry
+	webhookMaxDelay    = 6 * time.Hour    //longest delay between retries
+	webhookMaxAttempts = 10               //attempts before a delivery fails
+	webhookBatch       = 100              //deliveries sent per check of the queue
+)
+
+//Statuses of a Delivery.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+//SignatureHeader is the header carrying the signature of a webhook payload.
+const SignatureHeader = "X-Admin-Signature"
+
+//webhookClient is the client deliveries are sent with.
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+//Webhook is a url that is posted a WebhookPayload after every successful write
+//made by the admin. Name identifies the hook in the delivery log. Collections
+//and Actions select the writes sent to the hook, and empty means every one. The
+//payload is signed with Secret, see VerifyWebhook.
+type Webhook struct {
+	Name        string
+	URL         string
+	Secret      []byte
+	Collections []string
+	Actions     []string
+}
+
+//matches returns if the write is selected by the hook.
+func (w Webhook) matches(coll, action string) bool {
+	return selects(w.Collections, coll) && selects(w.Actions, action)
+}
+
+//selects returns if the value is in the list, or the list is empty.
+func selects(list []string, val string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, item := range list {
+		if item == val {
+			return true
+		}
+	}
+	return false
+}
+
+//WebhookPayload is the JSON posted to a Webhook. Values are the values of the
+//object after the write, or before it for deletes, and Changes are the same as
+//in the AuditEntry for the write.
+type WebhookPayload struct {
+	Hook       string                 `json:"hook"`
+	User       string                 `json:"user"`
+	Time       time.Time              `json:"time"`
+	Collection string                 `json:"collection"`
+	Object     string                 `json:"object"`
+	Action     string                 `json:"action"`
+	Values     map[string]interface{} `json:"values"`
+	Changes    []Change               `json:"changes"`
+}
+
+//Delivery is a payload queued for sending to a Webhook. Failed attempts are
+//retried with exponential backoff until webhookMaxAttempts, with Next being
+//the time of the next attempt. Code and Error describe the last attempt.
+type Delivery struct {
+	ID       bson.ObjectId `bson:"_id,omitempty"`
+	Hook     string
+	URL      string
+	Body     string
+	Created  time.Time
+	Status   string
+	Attempts int
+	Next     time.Time
+	Code     int
+	Error    string
+}
+
+//DeliveryStore is the persistent queue and log of webhook deliveries, so that
+//pending deliveries survive restarts. Due returns at most limit pending
+//deliveries whose Next attempt is not after now, and Recent returns the
+//deliveries newest first after skipping skip of them and returning at most
+//limit, along with the total number of deliveries.
+type DeliveryStore interface {
+	Enqueue(Delivery) error
+	Due(now time.Time, limit int) ([]Delivery, error)
+	Save(Delivery) error
+	Recent(skip, limit int) ([]Delivery, int, error)
+}
+
+//CollectionDeliveries is a DeliveryStore that keeps every delivery as a
+//document in a mongo collection.
+type CollectionDeliveries struct {
+	Session    *mgo.Session
+	DB         string
+	Collection string
+}
+
+//coll returns the mgo.Collection for the deliveries.
+func (c CollectionDeliveries) coll() *mgo.Collection {
+	return c.Session.DB(c.DB).C(c.Collection)
+}
+
+//Enqueue implements the DeliveryStore interface.
+func (c CollectionDeliveries) Enqueue(d Delivery) error {
+	if d.ID == "" {
+		d.ID = bson.NewObjectId()
+	}
+	return c.coll().Insert(d)
+}
+
+//Due implements the DeliveryStore interface.
+func (c CollectionDeliveries) Due(now time.Time, limit int) ([]Delivery, error) {
+	var ds []Delivery
+	query := bson.M{"status": DeliveryPending, "next": bson.M{"$lte": now}}
+	iter := c.coll().Find(query).Sort(bson.M{"next": 1}).Limit(limit).Iter()
+	for {
+		var d Delivery
+		if !iter.Next(&d) {
+			break
+		}
+		ds = append(ds, d)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	return ds, nil
+}
+
+//Save implements the DeliveryStore interface.
+func (c CollectionDeliveries) Save(d Delivery) error {
+	return c.coll().Update(bson.M{"_id": d.ID}, d)
+}
+
+//Recent implements the DeliveryStore interface.
+func (c CollectionDeliveries) Recent(skip, limit int) ([]Delivery, int, error) {
+	query := c.coll().Find(nil)
+	total, err := query.Count()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var ds []Delivery
+	iter := query.Sort(bson.M{"created": -1}).Skip(skip).Limit(limit).Iter()
+	for {
+		var d Delivery
+		if !iter.Next(&d) {
+			break
+		}
+		ds = append(ds, d)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, 0, err
+	}
+	return ds, total, nil
+}
+
+//SignWebhook returns the value of the SignatureHeader for the body signed with
+//the secret: the hex encoded HMAC-SHA256 prefixed with "sha256=".
+func SignWebhook(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+//VerifyWebhook reads the body of a webhook request and returns it if it is
+//signed with the secret. It is meant for the services receiving webhooks.
+func VerifyWebhook(secret []byte, req *http.Request) ([]byte, error) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	sig := req.Header.Get(SignatureHeader)
+	if !hmac.Equal([]byte(sig), []byte(SignWebhook(secret, body))) {
+		return nil, fmt.Errorf("Invalid webhook signature")
+	}
+	return body, nil
+}
+
+//checkWebhooks panics if the webhooks have missing or duplicate names, no url,
+//or there is nowhere to queue their deliveries.
+func (a *Admin) checkWebhooks() {
+	if len(a.Webhooks) > 0 && a.Deliveries == nil {
+		panic("Webhooks configured without Deliveries")
+	}
+
+	seen := map[string]bool{}
+	for _, hook := range a.Webhooks {
+		if hook.Name == "" || hook.URL == "" {
+			panic(fmt.Sprintf("Webhook %q needs a Name and URL", hook.Name))
+		}
+		if seen[hook.Name] {
+			panic(fmt.Sprintf("Duplicate webhook %s", hook.Name))
+		}
+		seen[hook.Name] = true
+	}
+}
+
+//findWebhook returns the webhook with the name.
+func (a *Admin) findWebhook(name string) (Webhook, bool) {
+	for _, hook := range a.Webhooks {
+		if hook.Name == name {
+			return hook, true
+		}
+	}
+	return Webhook{}, false
+}
+
+//notify queues a delivery of the write to every webhook that selects it. The
+//write already happened, so errors are only logged.
+func (a *Admin) notify(e AuditEntry, values map[string]interface{}) {
+	var queued bool
+	for _, hook := range a.Webhooks {
+		if !hook.matches(e.Collection, e.Action) {
+			continue
+		}
+
+		body, err := json.Marshal(WebhookPayload{
+			Hook:       hook.Name,
+			User:       e.User,
+			Time:       e.Time,
+			Collection: e.Collection,
+			Object:     e.Object,
+			Action:     e.Action,
+			Values:     values,
+			Changes:    e.Changes,
+		})
+		if err == nil {
+			err = a.Deliveries.Enqueue(Delivery{
+				Hook:    hook.Name,
+				URL:     hook.URL,
+				Body:    string(body),
+				Created: e.Time,
+				Status:  DeliveryPending,
+				Next:    e.Time,
+			})
+		}
+		if err != nil {
+			a.logger.Printf("Error queueing webhook %s: %s", hook.Name, err)
+			continue
+		}
+		queued = true
+	}
+
+	//wake up the delivery loop without waiting on it
+	if queued && a.webhook_kick != nil {
+		select {
+		case a.webhook_kick <- true:
+		default:
+		}
+	}
+}
+
+//backoff returns the delay before the next attempt of a delivery that has
+//failed the number of attempts.
+func backoff(attempts int) time.Duration {
+	delay := webhookBackoff
+	for i := 1; i < attempts && delay < webhookMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > webhookMaxDelay {
+		delay = webhookMaxDelay
+	}
+	return delay
+}
+
+//DeliverWebhooks sends every queued delivery that is due, recording the result
+//of each attempt. It is called whenever a write is queued and every minute
+//while the admin has Webhooks, but can be called to deliver on demand.
+func (a *Admin) DeliverWebhooks() error {
+	return a.deliverDue(time.Now())
+}
+
+//deliverDue sends every queued delivery due at now.
+func (a *Admin) deliverDue(now time.Time) error {
+	a.webhook_mu.Lock()
+	defer a.webhook_mu.Unlock()
+
+	for {
+		due, err := a.Deliveries.Due(now, webhookBatch)
+		if err != nil {
+			return err
+		}
+		for _, d := range due {
+			if err := a.Deliveries.Save(a.attempt(d, now)); err != nil {
+				return err
+			}
+		}
+		if len(due) < webhookBatch {
+			return nil
+		}
+	}
+}
+
+//attempt sends the delivery once and returns it updated with the result.
+func (a *Admin) attempt(d Delivery, now time.Time) Delivery {
+	d.Attempts++
+	d.Code, d.Error = 0, ""
+
+	hook, ok := a.findWebhook(d.Hook)
+	if !ok {
+		d.Status, d.Error = DeliveryFailed, "Unknown webhook"
+		return d
+	}
+
+	req, err := http.NewRequest("POST", d.URL, bytes.NewBufferString(d.Body))
+	if err == nil {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Admin-Delivery", d.ID.Hex())
+		req.Header.Set(SignatureHeader, SignWebhook(hook.Secret, []byte(d.Body)))
+
+		var resp *http.Response
+		if resp, err = webhookClient.Do(req); err == nil {
+			resp.Body.Close()
+			d.Code = resp.StatusCode
+			if resp.StatusCode < 200 || resp.StatusCode > 299 {
+				err = fmt.Errorf("Unexpected status: %s", resp.Status)
+			}
+		}
+	}
+
+	switch {
+	case err == nil:
+		d.Status = DeliveryDelivered
+	case d.Attempts >= webhookMaxAttempts:
+		d.Status, d.Error = DeliveryFailed, err.Error()
+	default:
+		d.Error, d.Next = err.Error(), now.Add(backoff(d.Attempts))
+	}
+	return d
+}
+
+//deliverWebhooks calls DeliverWebhooks whenever a write is queued, and every
+//webhookInterval for retries, until the admin is closed.
+func (a *Admin) deliverWebhooks() {
+	ticker := time.NewTicker(webhookInterval)
+	defer ticker.Stop()
+	for {
+		if err := a.DeliverWebhooks(); err != nil {
+			a.logger.Printf("Error delivering webhooks: %s", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-a.webhook_kick:
+		case <-a.done:
+			return
+		}
+	}
+}
+
+//Presents the log of webhook deliveries, newest first
+func (a *Admin) webhookLog(w http.ResponseWriter, req *http.Request) {
+	coll, id := parseRequest(req.URL.Path)
+
+	//ensure there are deliveries and nothing else in the path
+	if a.Deliveries == nil || coll != "" || id != "" {
+		a.Renderer.NotFound(w, req)
+		return
+	}
+
+	query := req.URL.Query()
+	page, numpage := grabInt(query, "page", 1), grabInt(query, "numpage", 20)
+	if page < 1 {
+		page = 1
+	}
+	if numpage < 1 {
+		numpage = 1
+	}
+
+	deliveries, total, err := a.Deliveries.Recent(numpage*(page-1), numpage)
+	if err != nil {
+		a.Renderer.InternalError(w, req, err)
+		return
+	}
+
+	pages := (total + numpage - 1) / numpage
+	if pages < 1 {
+		pages = 1
+	}
+
+	a.renderWebhooks(w, req, WebhookContext{
+		BaseContext: a.baseContext(req),
+		Deliveries:  deliveries,
+		Pagination: Pagination{
+			Pages:       pages,
+			CurrentPage: page,
+			query:       query,
+		},
+	})
+}
@@ -0,0 +1,176 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"launchpad.net/mgo/bson"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+//memoryDeliveries is a DeliveryStore kept in memory for tests.
+type memoryDeliveries struct {
+	mu sync.Mutex
+	ds []Delivery
+}
+
+func (m *memoryDeliveries) Enqueue(d Delivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d.ID = bson.ObjectId(fmt.Sprint(len(m.ds)))
+	m.ds = append(m.ds, d)
+	return nil
+}
+
+func (m *memoryDeliveries) Due(now time.Time, limit int) (due []Delivery, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, d := range m.ds {
+		if d.Status == DeliveryPending && !d.Next.After(now) && len(due) < limit {
+			due = append(due, d)
+		}
+	}
+	return
+}
+
+func (m *memoryDeliveries) Save(d Delivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.ds {
+		if m.ds[i].ID == d.ID {
+			m.ds[i] = d
+		}
+	}
+	return nil
+}
+
+func (m *memoryDeliveries) Recent(skip, limit int) ([]Delivery, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.ds, len(m.ds), nil
+}
+
+func TestBackoff(t *testing.T) {
+	cases := map[int]time.Duration{
+		1:  webhookBackoff,
+		2:  2 * webhookBackoff,
+		3:  4 * webhookBackoff,
+		50: webhookMaxDelay,
+	}
+	for attempts, expected := range cases {
+		if got := backoff(attempts); got != expected {
+			t.Errorf("%d: Expected %s. Got %s", attempts, expected, got)
+		}
+	}
+}
+
+func TestWebhookMatches(t *testing.T) {
+	hook := Webhook{Collections: []string{"admin_test.T12"}, Actions: []string{ActionDelete}}
+	if !hook.matches("admin_test.T12", ActionDelete) {
+		t.Error("Expected the hook to match")
+	}
+	if hook.matches("admin_test.T12", ActionUpdate) || hook.matches("admin_test.T10", ActionDelete) {
+		t.Error("Expected the hook to only match deletes of T12")
+	}
+	if !(Webhook{}).matches("admin_test.T10", ActionCreate) {
+		t.Error("Expected an empty hook to match everything")
+	}
+}
+
+func TestDeliverWebhooks(t *testing.T) {
+	secret := []byte("secret")
+
+	var mu sync.Mutex
+	var received []WebhookPayload
+	fail := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := VerifyWebhook(secret, req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if fail {
+			fail = false
+			http.Error(w, "try again", http.StatusInternalServerError)
+			return
+		}
+
+		var p WebhookPayload
+		if err := json.Unmarshal(body, &p); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		received = append(received, p)
+	}))
+	defer server.Close()
+
+	store := &memoryDeliveries{}
+	h := &Admin{
+		Deliveries: store,
+		Webhooks: []Webhook{
+			{Name: "updates", URL: server.URL, Secret: secret, Actions: []string{ActionUpdate}},
+			{Name: "deletes", URL: server.URL, Secret: secret, Actions: []string{ActionDelete}},
+		},
+	}
+
+	now := time.Now()
+	h.notify(AuditEntry{
+		User:       "bob",
+		Time:       now,
+		Collection: "admin_test.T12",
+		Object:     "4f07c34779bf562daff8640c",
+		Action:     ActionUpdate,
+		Changes:    []Change{{"Name", "foo", "bar"}},
+	}, map[string]interface{}{"Name": "bar"})
+
+	if len(store.ds) != 1 || store.ds[0].Hook != "updates" {
+		t.Fatalf("Expected one delivery to the updates hook. Got %v", store.ds)
+	}
+
+	//the first attempt fails and is retried later
+	if err := h.deliverDue(now); err != nil {
+		t.Fatal(err)
+	}
+	d := store.ds[0]
+	if d.Status != DeliveryPending || d.Attempts != 1 || d.Code != http.StatusInternalServerError || !d.Next.Equal(now.Add(webhookBackoff)) {
+		t.Fatalf("Expected a pending retry. Got %+v", d)
+	}
+
+	//not due yet
+	if err := h.deliverDue(now); err != nil {
+		t.Fatal(err)
+	}
+	if store.ds[0].Attempts != 1 {
+		t.Fatalf("Expected no attempt before the backoff. Got %+v", store.ds[0])
+	}
+
+	if err := h.deliverDue(now.Add(webhookBackoff)); err != nil {
+		t.Fatal(err)
+	}
+	if d := store.ds[0]; d.Status != DeliveryDelivered || d.Attempts != 2 || d.Error != "" {
+		t.Fatalf("Expected the delivery to succeed. Got %+v", d)
+	}
+
+	if len(received) != 1 {
+		t.Fatalf("Expected one payload. Got %v", received)
+	}
+	p := received[0]
+	if p.Hook != "updates" || p.User != "bob" || p.Action != ActionUpdate || p.Values["Name"] != "bar" || len(p.Changes) != 1 {
+		t.Fatalf("Unexpected payload %+v", p)
+	}
+}
+
+func TestVerifyWebhook(t *testing.T) {
+	req, _ := http.NewRequest("POST", "/", nil)
+	req.Body = http.NoBody
+	req.Header.Set(SignatureHeader, SignWebhook([]byte("other"), nil))
+	if _, err := VerifyWebhook([]byte("secret"), req); err == nil {
+		t.Fatal("Expected an error with the wrong secret")
+	}
+}